@@ -6,17 +6,23 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/semyon-ancherbak/sueta/internal/config"
 	"github.com/semyon-ancherbak/sueta/internal/handler"
 	"github.com/semyon-ancherbak/sueta/internal/llm"
+	"github.com/semyon-ancherbak/sueta/internal/models"
 	"github.com/semyon-ancherbak/sueta/internal/repository"
 	"github.com/semyon-ancherbak/sueta/internal/telegram"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
@@ -35,14 +41,96 @@ func main() {
 	}()
 	log.Println("Подключение к базе данных установлено")
 
-	llmClient := llm.NewClient(cfg.OpenRouterKey)
-	log.Println("LLM клиент инициализирован")
+	prompts, err := llm.NewPromptStore(cfg.PromptPath)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки системного промпта: %v", err)
+	}
+	go reloadPromptOnSIGHUP(prompts)
 
-	tgClient := telegram.NewClient(cfg.TelegramToken, repo)
+	provider, err := newLLMProvider(cfg)
+	if err != nil {
+		log.Fatalf("Ошибка инициализации провайдера LLM: %v", err)
+	}
+	llmClient := llm.NewClient(provider, prompts, llm.ClientOptions{
+		Model:          cfg.LLMModel,
+		FallbackModels: cfg.LLMFallbackModels,
+		Sampling:       cfg.LLMSampling,
+		Retry: llm.RetryPolicy{
+			MaxAttempts: cfg.LLMRetryAttempts,
+			BaseDelay:   cfg.LLMRetryBaseDelay,
+			MaxDelay:    cfg.LLMRetryMaxDelay,
+		},
+		Breaker: llm.NewCircuitBreaker(cfg.LLMBreakerThreshold, cfg.LLMBreakerCooldown),
+		Context: llm.ContextConfig{
+			Tokens:       cfg.ContextTokens,
+			AmbientRatio: cfg.ContextAmbientRatio,
+			Window:       cfg.LLMContextWindow,
+			Windows:      cfg.LLMContextWindows,
+		},
+	})
+	log.Printf("LLM клиент инициализирован: провайдер %s, модель %s, резервные модели: %v, параметры генерации: %s",
+		provider.Name(), cfg.LLMModel, cfg.LLMFallbackModels, formatSampling(cfg.LLMSampling))
+
+	tgClient := telegram.NewClient(cfg.TelegramAPIURL, cfg.TelegramToken, repo)
 	log.Println("Telegram бот клиент инициализирован")
 
-	botName := "Жорик" // Имя бота
-	webhookHandler := handler.NewWebhookHandler(repo, llmClient, tgClient, botName, cfg)
+	me, err := tgClient.GetMe(ctx)
+	if err != nil {
+		log.Fatalf("Ошибка получения информации о боте: %v", err)
+	}
+	log.Printf("Бот: @%s [ID: %d]", me.Username, me.ID)
+
+	webhookHandler := handler.NewWebhookHandler(repo, llmClient, tgClient, me, cfg)
+	metricsServer := runMetrics(webhookHandler, cfg)
+
+	if cfg.UpdateMode == config.UpdateModePolling {
+		runPolling(ctx, webhookHandler, tgClient, cfg)
+	} else {
+		runWebhook(ctx, webhookHandler, tgClient, cfg)
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Close(); err != nil {
+			log.Printf("Ошибка остановки сервера метрик: %v", err)
+		}
+	}
+}
+
+// runMetrics запускает отдельный HTTP сервер метрик на METRICS_ADDR, чтобы метрики не были доступны
+// на публичном адресе webhook. Возвращает nil, если адрес не задан.
+func runMetrics(webhookHandler *handler.WebhookHandler, cfg *config.Config) *http.Server {
+	if cfg.MetricsAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", webhookHandler.HandleMetrics)
+	server := &http.Server{
+		Addr:         cfg.MetricsAddr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Метрики доступны на %s/metrics", cfg.MetricsAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Ошибка сервера метрик: %v", err)
+		}
+	}()
+	return server
+}
+
+// runWebhook запускает HTTP сервер для приема обновлений через webhook
+func runWebhook(
+	ctx context.Context,
+	webhookHandler *handler.WebhookHandler,
+	tgClient *telegram.Client,
+	cfg *config.Config,
+) {
+	if err := registerWebhook(ctx, tgClient, cfg); err != nil {
+		log.Fatalf("Ошибка регистрации webhook: %v", err)
+	}
 
 	router := webhookHandler.SetupRouter()
 	server := &http.Server{
@@ -60,10 +148,7 @@ func main() {
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
+	waitForShutdownSignal()
 	log.Println("Получен сигнал завершения, выключение сервера...")
 
 	// Graceful shutdown
@@ -74,5 +159,149 @@ func main() {
 		log.Printf("Ошибка при завершении работы сервера: %v", err)
 	}
 
+	if cfg.WebhookDeleteOnShutdown {
+		if err := tgClient.DeleteWebhook(ctx, false); err != nil {
+			log.Printf("Ошибка удаления webhook: %v", err)
+		} else {
+			log.Println("Webhook удален")
+		}
+	}
+
+	drainUpdates(webhookHandler)
+
 	log.Println("Сервер остановлен")
 }
+
+// runPolling получает обновления через getUpdates без HTTP сервера
+func runPolling(
+	ctx context.Context,
+	webhookHandler *handler.WebhookHandler,
+	tgClient *telegram.Client,
+	cfg *config.Config,
+) {
+	// getUpdates не работает, пока у бота зарегистрирован webhook
+	if err := tgClient.DeleteWebhook(ctx, false); err != nil {
+		log.Fatalf("Ошибка удаления webhook перед запуском long polling: %v", err)
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	poller := handler.NewPoller(webhookHandler, cfg.PollingTimeout)
+	done := make(chan error, 1)
+	go func() {
+		done <- poller.Run(pollCtx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("Ошибка long polling: %v", err)
+		}
+		drainUpdates(webhookHandler)
+		return
+	case <-shutdownSignal():
+	}
+
+	log.Println("Получен сигнал завершения, остановка long polling...")
+	cancel()
+	if err := <-done; err != nil {
+		log.Printf("Ошибка long polling: %v", err)
+	}
+
+	drainUpdates(webhookHandler)
+}
+
+// drainUpdates дожидается обработки обновлений, уже принятых в очередь
+func drainUpdates(webhookHandler *handler.WebhookHandler) {
+	// Запас больше таймаута запроса к LLM, чтобы начатые ответы успели отправиться
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	if err := webhookHandler.Shutdown(ctx); err != nil {
+		log.Printf("Ошибка при остановке обработки обновлений: %v", err)
+	}
+}
+
+// newLLMProvider создает провайдера LLM, выбранного в конфигурации
+func newLLMProvider(cfg *config.Config) (llm.Provider, error) {
+	providerCfg := llm.ProviderConfig{
+		Name:    cfg.LLMProvider,
+		BaseURL: cfg.LLMBaseURL,
+		APIKey:  cfg.LLMAPIKey,
+	}
+	if cfg.LLMProvider == config.LLMProviderMock && cfg.LLMMockScript != "" {
+		script, err := llm.LoadMockScript(cfg.LLMMockScript)
+		if err != nil {
+			return nil, err
+		}
+		providerCfg.MockScript = script
+	}
+	return llm.NewProvider(providerCfg)
+}
+
+// formatSampling описывает параметры генерации для лога
+func formatSampling(p models.SamplingParams) string {
+	if p.IsZero() {
+		return "по умолчанию провайдера"
+	}
+	return p.String()
+}
+
+// reloadPromptOnSIGHUP перечитывает системный промпт при получении SIGHUP
+func reloadPromptOnSIGHUP(prompts *llm.PromptStore) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Println("Получен SIGHUP, перечитываем системный промпт")
+		if err := prompts.Reload(); err != nil {
+			log.Printf("Ошибка перезагрузки промпта, продолжаем с прежним: %v", err)
+		}
+	}
+}
+
+func shutdownSignal() <-chan os.Signal {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	return quit
+}
+
+func waitForShutdownSignal() {
+	<-shutdownSignal()
+}
+
+// registerWebhook регистрирует webhook в Telegram и сообщает о накопившихся ошибках доставки
+func registerWebhook(ctx context.Context, tgClient *telegram.Client, cfg *config.Config) error {
+	webhookURL := strings.TrimRight(cfg.WebhookURL, "/")
+	if cfg.WebhookLegacyTokenPath {
+		webhookURL += "/" + cfg.TelegramToken
+	}
+
+	request := telegram.SetWebhookRequest{
+		URL:            webhookURL,
+		SecretToken:    cfg.WebhookSecret,
+		AllowedUpdates: handler.AllowedUpdates,
+	}
+	if err := tgClient.SetWebhook(ctx, request); err != nil {
+		return err
+	}
+	log.Printf("Webhook зарегистрирован: %s (путь с токеном: %t)",
+		strings.TrimRight(cfg.WebhookURL, "/"), cfg.WebhookLegacyTokenPath)
+
+	info, err := tgClient.GetWebhookInfo(ctx)
+	if err != nil {
+		// Webhook уже установлен, поэтому отсутствие диагностики не критично
+		log.Printf("Не удалось получить информацию о webhook: %v", err)
+		return nil
+	}
+
+	if info.PendingUpdateCount > 0 {
+		log.Printf("Ожидают доставки обновлений: %d", info.PendingUpdateCount)
+	}
+	if info.LastErrorMessage != "" {
+		log.Printf("Последняя ошибка доставки webhook (%s): %s",
+			info.LastErrorTime().Format("2006-01-02 15:04:05"), info.LastErrorMessage)
+	}
+
+	return nil
+}
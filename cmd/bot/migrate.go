@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/semyon-ancherbak/sueta/internal/config"
+	"github.com/semyon-ancherbak/sueta/internal/repository"
+)
+
+const migrateUsage = `Использование: bot migrate <команда>
+  status    - показать примененные и ожидающие миграции
+  up        - применить все ожидающие миграции
+  down [N]  - откатить последние N миграций (по умолчанию 1)`
+
+// runMigrate выполняет подкоманду migrate и возвращает код завершения процесса
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	dbPath := config.LoadDatabasePath()
+	repo, err := repository.Open(dbPath)
+	if err != nil {
+		log.Printf("Ошибка подключения к базе данных: %v", err)
+		return 1
+	}
+	defer repo.Close(context.Background())
+
+	ctx := context.Background()
+	switch args[0] {
+	case "status":
+		err = printMigrationStatus(ctx, repo)
+	case "up":
+		var count int
+		count, err = repo.MigrateUp(ctx)
+		if err == nil {
+			log.Printf("Применено миграций: %d", count)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "Количество миграций для отката должно быть положительным числом")
+				return 2
+			}
+		}
+		var count int
+		count, err = repo.MigrateDown(ctx, steps)
+		if err == nil {
+			log.Printf("Откачено миграций: %d", count)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Printf("Ошибка миграции базы данных %s: %v", dbPath, err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, repo *repository.SQLiteRepository) error {
+	version, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, err := repo.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Версия схемы: %d\n", version)
+	for _, s := range statuses {
+		state := "ожидает"
+		if s.Applied {
+			state = "применена " + s.AppliedAt.Local().Format("02.01.2006 15:04:05")
+		}
+		fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+	}
+	if len(statuses) > 0 && version > statuses[len(statuses)-1].Version {
+		fmt.Printf("Внимание: %v\n", repository.ErrDatabaseNewer)
+	}
+	return nil
+}
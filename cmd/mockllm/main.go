@@ -0,0 +1,44 @@
+// Команда mockllm - локальная замена LLM API для запуска бота без сети и ключей.
+// Отвечает по сценарию (или повторяет сообщение пользователя) через OpenAI-совместимый API
+// и нативный API Ollama:
+//
+//	go run ./cmd/mockllm -addr :8090 -script replies.txt
+//	LLM_PROVIDER=openai LLM_BASE_URL=http://localhost:8090/v1 ./bot
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/semyon-ancherbak/sueta/internal/llm"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "адрес HTTP сервера")
+	scriptPath := flag.String("script", "", "файл сценария: по ответу на строку (без него ответы повторяют сообщение пользователя)")
+	flag.Parse()
+
+	var script []string
+	if *scriptPath != "" {
+		var err error
+		script, err = llm.LoadMockScript(*scriptPath)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки сценария: %v", err)
+		}
+	}
+
+	provider := llm.NewMockProvider(script...)
+	log.Printf("Мок LLM запущен на %s (ответов в сценарии: %d)", *addr, len(script))
+	if err := http.ListenAndServe(*addr, logRequests(llm.NewMockServer(provider))); err != nil {
+		log.Fatalf("Ошибка запуска сервера: %v", err)
+	}
+}
+
+// logRequests пишет в лог каждый запрос к мок-серверу
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,21 +1,75 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// Режимы получения обновлений от Telegram
+const (
+	UpdateModeWebhook = "webhook"
+	UpdateModePolling = "polling"
+)
+
+// Провайдеры LLM
+const (
+	LLMProviderOpenAI = "openai" // OpenAI-совместимый API (по умолчанию - OpenRouter)
+	LLMProviderOllama = "ollama" // Нативный API Ollama
+	LLMProviderMock   = "mock"   // Сценарий ответов без сети, для тестов и разработки
 )
 
 type Config struct {
-	Port          string
-	TelegramToken string
-	WebhookURL    string
-	DatabasePath  string
-	OpenRouterKey string
+	Port                    string
+	UpdateMode              string
+	PollingTimeout          int
+	WorkerCount             int
+	WorkerQueueSize         int
+	UpdateTimeout           time.Duration
+	MetricsAddr             string
+	TelegramToken           string
+	TelegramAPIURL          string
+	WebhookURL              string
+	WebhookSecret           string
+	WebhookLegacyTokenPath  bool
+	WebhookDeleteOnShutdown bool
+	DatabasePath            string
+	LLMProvider             string
+	LLMBaseURL              string
+	LLMAPIKey               string
+	LLMModel                string
+	LLMMockScript           string
+	LLMSampling             models.SamplingParams
+	LLMFallbackModels       []string
+	LLMRetryAttempts        int
+	LLMRetryBaseDelay       time.Duration
+	LLMRetryMaxDelay        time.Duration
+	LLMBreakerThreshold     int
+	LLMBreakerCooldown      time.Duration
+	LLMStream               bool
+	LLMStreamEditInterval   time.Duration
+	PromptPath              string
+	BotName                 string
+	BotAliases              []string
+	ContextTokens           int
+	ContextAmbientRatio     float64
+	ContextMaxMessages      int
+	LLMContextWindow        int
+	LLMContextWindows       map[string]int
 }
 
+// webhookSecretPattern описывает допустимые символы secret_token для setWebhook
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 func Load() (*Config, error) {
 	// Пытаемся загрузить .env файл (если существует)
 	if err := godotenv.Load(); err != nil {
@@ -23,16 +77,68 @@ func Load() (*Config, error) {
 	}
 
 	config := &Config{
-		Port:          getEnvWithDefault("PORT", "8080"),
-		TelegramToken: getEnv("TELEGRAM_TOKEN"),
-		WebhookURL:    getEnv("WEBHOOK_URL"),
-		DatabasePath:  getEnvWithDefault("DATABASE_PATH", "./data/sueta.db"),
-		OpenRouterKey: getEnv("OPENROUTER_API_KEY"),
+		Port:                    getEnvWithDefault("PORT", "8080"),
+		UpdateMode:              getEnvWithDefault("UPDATE_MODE", UpdateModeWebhook),
+		PollingTimeout:          getEnvInt("POLLING_TIMEOUT", 25),
+		WorkerCount:             getEnvInt("WORKER_COUNT", 4),
+		WorkerQueueSize:         getEnvInt("WORKER_QUEUE_SIZE", 100),
+		UpdateTimeout:           getEnvDuration("UPDATE_TIMEOUT", 5*time.Minute),
+		MetricsAddr:             getEnv("METRICS_ADDR"),
+		TelegramToken:           getEnv("TELEGRAM_TOKEN"),
+		TelegramAPIURL:          getEnvWithDefault("TELEGRAM_API_URL", "https://api.telegram.org"),
+		WebhookURL:              getEnv("WEBHOOK_URL"),
+		WebhookSecret:           getEnv("WEBHOOK_SECRET"),
+		WebhookLegacyTokenPath:  getEnvBool("WEBHOOK_LEGACY_TOKEN_PATH", false),
+		WebhookDeleteOnShutdown: getEnvBool("WEBHOOK_DELETE_ON_SHUTDOWN", false),
+		DatabasePath:            databasePath(),
+		LLMProvider:             getEnvWithDefault("LLM_PROVIDER", LLMProviderOpenAI),
+		LLMBaseURL:              getEnv("LLM_BASE_URL"),
+		LLMAPIKey:               getEnvWithDefault("LLM_API_KEY", getEnv("OPENROUTER_API_KEY")),
+		LLMMockScript:           getEnv("LLM_MOCK_SCRIPT"),
+		LLMFallbackModels:       getEnvList("LLM_FALLBACK_MODELS", nil),
+		LLMRetryAttempts:        getEnvInt("LLM_RETRY_ATTEMPTS", 3),
+		LLMRetryBaseDelay:       getEnvDuration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond),
+		LLMRetryMaxDelay:        getEnvDuration("LLM_RETRY_MAX_DELAY", 10*time.Second),
+		LLMBreakerThreshold:     getEnvInt("LLM_BREAKER_THRESHOLD", 5),
+		LLMBreakerCooldown:      getEnvDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+		LLMStream:               getEnvBool("LLM_STREAM", false),
+		LLMStreamEditInterval:   getEnvDuration("LLM_STREAM_EDIT_INTERVAL", 2*time.Second),
+		PromptPath:              getEnv("PROMPT_PATH"),
+		BotName:                 getEnvWithDefault("BOT_NAME", "Жорик"),
+		BotAliases:              getEnvList("BOT_ALIASES", []string{"Жора", "Жорж"}),
+		ContextTokens:           getEnvInt("CONTEXT_TOKENS", 4000),
+		ContextAmbientRatio:     getEnvFloat("CONTEXT_AMBIENT_RATIO", 0.4),
+		ContextMaxMessages:      getEnvInt("CONTEXT_MAX_MESSAGES", 1000),
+		LLMContextWindow:        getEnvInt("LLM_CONTEXT_WINDOW", 8192),
+		LLMContextWindows:       getEnvIntMap("LLM_CONTEXT_WINDOWS"),
+	}
+
+	config.LLMModel = getEnvWithDefault("LLM_MODEL", defaultLLMModel(config.LLMProvider))
+	// Незаданные параметры генерации не передаются в API - провайдер использует свои значения по умолчанию
+	config.LLMSampling = models.SamplingParams{
+		Temperature:      getEnvOptionalFloat("LLM_TEMPERATURE"),
+		TopP:             getEnvOptionalFloat("LLM_TOP_P"),
+		MaxTokens:        getEnvOptionalInt("LLM_MAX_TOKENS"),
+		Stop:             models.ParseStopSequences(getEnv("LLM_STOP")),
+		PresencePenalty:  getEnvOptionalFloat("LLM_PRESENCE_PENALTY"),
+		FrequencyPenalty: getEnvOptionalFloat("LLM_FREQUENCY_PENALTY"),
+		Seed:             getEnvOptionalInt("LLM_SEED"),
 	}
 
 	if err := validateConfig(config); err != nil {
 		return nil, fmt.Errorf("ошибка в конфигурации: %w", err)
 	}
+
+	// Секрет передается Telegram при каждом запуске через setWebhook,
+	// поэтому если он не задан явно, достаточно случайного значения на время жизни процесса
+	if config.UpdateMode == UpdateModeWebhook && config.WebhookSecret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка генерации WEBHOOK_SECRET: %w", err)
+		}
+		config.WebhookSecret = secret
+		log.Println("WEBHOOK_SECRET не установлен, сгенерирован случайный секрет")
+	}
 	return config, nil
 }
 
@@ -41,11 +147,67 @@ func validateConfig(cfg *Config) error {
 	if cfg.TelegramToken == "" {
 		errors = append(errors, "TELEGRAM_TOKEN не установлен")
 	}
-	if cfg.OpenRouterKey == "" {
-		errors = append(errors, "OPENROUTER_API_KEY не установлен")
+	switch cfg.LLMProvider {
+	case LLMProviderOpenAI:
+		// Локальным OpenAI-совместимым серверам ключ обычно не нужен, OpenRouter без него не работает
+		if cfg.LLMBaseURL == "" && cfg.LLMAPIKey == "" {
+			errors = append(errors, "LLM_API_KEY (или OPENROUTER_API_KEY) не установлен")
+		}
+	case LLMProviderOllama, LLMProviderMock:
+	default:
+		errors = append(errors, fmt.Sprintf("LLM_PROVIDER должен быть %q, %q или %q",
+			LLMProviderOpenAI, LLMProviderOllama, LLMProviderMock))
+	}
+	switch cfg.UpdateMode {
+	case UpdateModeWebhook:
+		if cfg.WebhookURL == "" {
+			errors = append(errors, "WEBHOOK_URL не установлен")
+		}
+	case UpdateModePolling:
+		if cfg.PollingTimeout < 0 || cfg.PollingTimeout > 50 {
+			errors = append(errors, "POLLING_TIMEOUT должен быть от 0 до 50 секунд")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("UPDATE_MODE должен быть %q или %q", UpdateModeWebhook, UpdateModePolling))
+	}
+	if strings.TrimSpace(cfg.BotName) == "" {
+		errors = append(errors, "BOT_NAME не может быть пустым")
+	}
+	if cfg.WorkerCount < 1 {
+		errors = append(errors, "WORKER_COUNT должен быть положительным")
+	}
+	if cfg.WorkerQueueSize < 1 {
+		errors = append(errors, "WORKER_QUEUE_SIZE должен быть положительным")
+	}
+	if err := cfg.LLMSampling.Validate(); err != nil {
+		errors = append(errors, "параметры генерации LLM: "+err.Error())
+	}
+	if cfg.LLMRetryAttempts < 1 {
+		errors = append(errors, "LLM_RETRY_ATTEMPTS должен быть положительным")
+	}
+	if cfg.LLMRetryBaseDelay <= 0 || cfg.LLMRetryMaxDelay < cfg.LLMRetryBaseDelay {
+		errors = append(errors, "LLM_RETRY_BASE_DELAY должен быть положительным и не больше LLM_RETRY_MAX_DELAY")
+	}
+	if cfg.LLMStreamEditInterval < 500*time.Millisecond {
+		errors = append(errors, "LLM_STREAM_EDIT_INTERVAL должен быть не меньше 500ms")
 	}
-	if cfg.WebhookURL == "" {
-		errors = append(errors, "WEBHOOK_URL не установлен")
+	if cfg.ContextTokens < 1 {
+		errors = append(errors, "CONTEXT_TOKENS должен быть положительным")
+	}
+	if cfg.ContextAmbientRatio < 0 || cfg.ContextAmbientRatio > 1 {
+		errors = append(errors, "CONTEXT_AMBIENT_RATIO должен быть от 0 до 1")
+	}
+	if cfg.ContextMaxMessages < 1 {
+		errors = append(errors, "CONTEXT_MAX_MESSAGES должен быть положительным")
+	}
+	if cfg.LLMContextWindow < 1 {
+		errors = append(errors, "LLM_CONTEXT_WINDOW должен быть положительным")
+	}
+	if cfg.LLMSampling.MaxTokens != nil && *cfg.LLMSampling.MaxTokens >= cfg.LLMContextWindow {
+		errors = append(errors, "LLM_MAX_TOKENS должен быть меньше LLM_CONTEXT_WINDOW")
+	}
+	if cfg.WebhookSecret != "" && !webhookSecretPattern.MatchString(cfg.WebhookSecret) {
+		errors = append(errors, "WEBHOOK_SECRET должен содержать от 1 до 256 символов A-Z, a-z, 0-9, _ или -")
 	}
 	if len(errors) > 0 {
 		return fmt.Errorf("конфигурация содержит ошибки: %s", errors)
@@ -53,6 +215,39 @@ func validateConfig(cfg *Config) error {
 	return nil
 }
 
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// defaultLLMModel возвращает модель по умолчанию для провайдера
+func defaultLLMModel(provider string) string {
+	switch provider {
+	case LLMProviderOllama:
+		return "llama3.1"
+	case LLMProviderMock:
+		return "mock"
+	default:
+		return "anthropic/claude-3.5-sonnet"
+	}
+}
+
+// LoadDatabasePath возвращает путь к базе данных без проверки остальной конфигурации
+// (для служебных команд вроде migrate, которым не нужны токены).
+func LoadDatabasePath() string {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Файл .env не найден, используем переменные окружения")
+	}
+	return databasePath()
+}
+
+func databasePath() string {
+	return getEnvWithDefault("DATABASE_PATH", "./data/sueta.db")
+}
+
 func getEnv(key string) string {
 	return os.Getenv(key)
 }
@@ -63,3 +258,120 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList читает список значений, разделенных запятыми
+func getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvIntMap читает пары "ключ=число", разделенные запятыми. Некорректные пары пропускаются.
+// Ключ отделяется по последнему "=", так что в нем допустимы любые другие символы.
+func getEnvIntMap(key string) map[string]int {
+	items := make(map[string]int)
+	for _, item := range getEnvList(key, nil) {
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			log.Printf("Некорректное значение %s: %q, ожидается ключ=число", key, item)
+			continue
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+		if err != nil || parsed < 1 {
+			log.Printf("Некорректное значение %s: %q, ожидается ключ=число", key, item)
+			continue
+		}
+		items[strings.TrimSpace(item[:i])] = parsed
+	}
+	return items
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используем %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используем %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используем %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvOptionalFloat читает необязательное число: nil, если переменная не задана или некорректна
+func getEnvOptionalFloat(key string) *float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, параметр не используется", key, value)
+		return nil
+	}
+	return &parsed
+}
+
+// getEnvOptionalInt читает необязательное целое число: nil, если переменная не задана или некорректна
+func getEnvOptionalInt(key string) *int {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, параметр не используется", key, value)
+		return nil
+	}
+	return &parsed
+}
+
+// getEnvDuration читает длительность в формате time.ParseDuration ("500ms", "30s")
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используем %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
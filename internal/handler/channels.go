@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// processChannelPost сохраняет пост канала в историю. В каналах бот только архивирует посты:
+// отвечать там некому, а обсуждение идет в привязанной группе.
+func (h *WebhookHandler) processChannelPost(ctx context.Context, updateID int, post *Message) error {
+	if post.Chat == nil {
+		return nil
+	}
+	if err := h.saveChat(ctx, post.Chat, nil); err != nil {
+		log.Printf("Ошибка сохранения чата: %v", err)
+	}
+	return h.saveMessage(ctx, updateID, post, false)
+}
+
+// discussedPost возвращает пост канала, под которым оставлен комментарий в группе обсуждений,
+// или nil, если сообщение не комментарий к посту. Комментарии - это ответы в ветке,
+// корень которой - автоматически пересланный из канала пост.
+func (h *WebhookHandler) discussedPost(ctx context.Context, msg *Message) *models.MessageDocument {
+	if msg.IsTopicMessage {
+		return nil
+	}
+
+	rootID := msg.MessageThreadID
+	if rootID == 0 && msg.ReplyToMessage != nil {
+		rootID = msg.ReplyToMessage.MessageID
+	}
+	if rootID == 0 {
+		return nil
+	}
+
+	root, err := h.repo.GetMessage(ctx, msg.Chat.ID, rootID)
+	if err != nil {
+		log.Printf("Ошибка получения корня ветки %d: %v", rootID, err)
+	}
+	if root != nil {
+		if root.IsAutomaticForward {
+			return root
+		}
+		return nil
+	}
+
+	// Пост мог быть опубликован до появления бота в группе - берем его из самого ответа
+	if reply := msg.ReplyToMessage; reply != nil && reply.MessageID == rootID && reply.IsAutomaticForward && reply.Chat != nil {
+		return messageDocument(0, reply, false)
+	}
+	return nil
+}
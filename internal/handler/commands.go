@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Command описывает команду бота
+type Command struct {
+	Name        string // Имя без "/", в нижнем регистре
+	Args        string // Описание аргументов для /help
+	Description string
+	AdminOnly   bool // Команду целиком могут выполнять только администраторы чата
+	Handler     func(ctx context.Context, msg *Message, args string) error
+}
+
+// commandRouter хранит зарегистрированные команды в порядке регистрации
+type commandRouter struct {
+	commands []*Command
+	byName   map[string]*Command
+}
+
+func newCommandRouter() *commandRouter {
+	return &commandRouter{byName: make(map[string]*Command)}
+}
+
+func (r *commandRouter) register(cmd *Command) {
+	if _, exists := r.byName[cmd.Name]; exists {
+		panic("команда зарегистрирована повторно: " + cmd.Name)
+	}
+	r.commands = append(r.commands, cmd)
+	r.byName[cmd.Name] = cmd
+}
+
+func (r *commandRouter) lookup(name string) *Command {
+	return r.byName[name]
+}
+
+// helpText формирует справку по всем зарегистрированным командам
+func (r *commandRouter) helpText() string {
+	var sb strings.Builder
+	sb.WriteString("Команды бота:\n")
+	for _, cmd := range r.commands {
+		sb.WriteString("/" + cmd.Name)
+		if cmd.Args != "" {
+			sb.WriteString(" " + cmd.Args)
+		}
+		sb.WriteString(" - " + cmd.Description)
+		if cmd.AdminOnly {
+			sb.WriteString(" (только администраторы)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// registerCommands регистрирует встроенные команды бота
+func (h *WebhookHandler) registerCommands() *commandRouter {
+	r := newCommandRouter()
+	r.register(&Command{
+		Name:        "start",
+		Description: "приветствие и краткая справка",
+		Handler:     h.handleStartCommand,
+	})
+	r.register(&Command{
+		Name:        "help",
+		Description: "список команд",
+		Handler:     h.handleHelpCommand,
+	})
+	r.register(&Command{
+		Name:        "reset",
+		Description: "забыть текущий разговор (история в базе сохраняется)",
+		AdminOnly:   true,
+		Handler:     h.handleResetCommand,
+	})
+	r.register(&Command{
+		Name:        "stats",
+		Description: "статистика чата (ответом на сообщение - статистика его автора)",
+		Handler:     h.handleStatsCommand,
+	})
+	r.register(&Command{
+		Name:        "search",
+		Args:        "<слова>",
+		Description: "поиск по истории чата",
+		Handler:     h.handleSearchCommand,
+	})
+	r.register(&Command{
+		Name:        "model",
+		Args:        "[модель|reset]",
+		Description: "показать или сменить модель LLM (смена - только администраторы)",
+		Handler:     h.handleModelCommand,
+	})
+	r.register(&Command{
+		Name:        "persona",
+		Args:        "[show|set|reset]",
+		Description: "персона бота в этом чате (изменение - только администраторы)",
+		Handler:     h.handlePersonaCommand,
+	})
+	r.register(&Command{
+		Name:        "params",
+		Args:        "[параметр значение|reset]",
+		Description: "параметры генерации LLM: temperature, max_tokens и другие (изменение - только администраторы)",
+		Handler:     h.handleParamsCommand,
+	})
+	r.register(&Command{
+		Name:        "context",
+		Args:        "[tokens|ambient]",
+		Description: "сколько истории чата видит бот (изменение - только администраторы)",
+		Handler:     h.handleContextCommand,
+	})
+	r.register(&Command{
+		Name:        "triggers",
+		Args:        "[list|add|remove]",
+		Description: "слова, на которые откликается бот (изменение - только администраторы)",
+		Handler:     h.handleTriggersCommand,
+	})
+	return r
+}
+
+// parseCommand разбирает команду вида "/name@bot args".
+// Возвращает имя команды в нижнем регистре без "/", username бота из суффикса (если есть) и аргументы.
+func parseCommand(text string) (name, bot, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", "", false
+	}
+
+	head, rest := cutWord(text)
+	name, bot, _ = strings.Cut(strings.TrimPrefix(head, "/"), "@")
+	if name == "" {
+		return "", "", "", false
+	}
+	return strings.ToLower(name), bot, rest, true
+}
+
+// cutWord отделяет первое слово от остального текста (переводы строк в остатке сохраняются)
+func cutWord(text string) (word, rest string) {
+	text = strings.TrimSpace(text)
+	i := strings.IndexFunc(text, unicode.IsSpace)
+	if i < 0 {
+		return text, ""
+	}
+	return text[:i], strings.TrimSpace(text[i:])
+}
+
+// commandFor возвращает команду, адресованную этому боту, или nil.
+// Неизвестная команда возвращается как unknown=true, если она явно адресована боту
+// (суффикс @username или личный чат) - в группах команды без суффикса могут быть для других ботов.
+func (h *WebhookHandler) commandFor(msg *Message) (cmd *Command, args string, unknown bool) {
+	name, bot, args, ok := parseCommand(msg.Text)
+	if !ok {
+		return nil, "", false
+	}
+	// Команда вида /command@other_bot адресована другому боту
+	if bot != "" && !h.isOwnUsername(bot) {
+		return nil, "", false
+	}
+
+	if cmd := h.commands.lookup(name); cmd != nil {
+		return cmd, args, false
+	}
+	explicit := bot != "" || (msg.Chat != nil && msg.Chat.Type == "private")
+	return nil, "", explicit
+}
+
+// handleCommand выполняет команду бота из сообщения (если сообщение не команда бота, ничего не делает)
+func (h *WebhookHandler) handleCommand(ctx context.Context, msg *Message) error {
+	cmd, args, unknown := h.commandFor(msg)
+	if unknown {
+		return h.reply(ctx, msg, "Неизвестная команда. Список команд: /help")
+	}
+	if cmd == nil {
+		return nil
+	}
+
+	log.Printf("Команда /%s в чате %d", cmd.Name, msg.Chat.ID)
+	if cmd.AdminOnly {
+		if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+			return err
+		}
+	}
+	return cmd.Handler(ctx, msg, args)
+}
+
+func (h *WebhookHandler) handleStartCommand(ctx context.Context, msg *Message, _ string) error {
+	text := fmt.Sprintf("Привет! Я %s. В группах обращайтесь ко мне по имени, через @%s или ответом на мое сообщение.\n\n%s",
+		h.cfg.BotName, h.me.Username, h.commands.helpText())
+	return h.reply(ctx, msg, text)
+}
+
+func (h *WebhookHandler) handleHelpCommand(ctx context.Context, msg *Message, _ string) error {
+	return h.reply(ctx, msg, h.commands.helpText())
+}
+
+func (h *WebhookHandler) handleResetCommand(ctx context.Context, msg *Message, _ string) error {
+	if err := h.repo.ResetContext(ctx, msg.Chat.ID, msg.topicID(), time.Unix(msg.Date, 0)); err != nil {
+		return fmt.Errorf("ошибка сброса контекста: %w", err)
+	}
+	log.Printf("Контекст чата %d (тема %d) сброшен", msg.Chat.ID, msg.topicID())
+	return h.reply(ctx, msg, "Контекст очищен: предыдущий разговор больше не учитывается.")
+}
+
+func (h *WebhookHandler) handleStatsCommand(ctx context.Context, msg *Message, _ string) error {
+	// Ответ на сообщение пользователя - статистика этого пользователя
+	if reply := msg.ReplyToMessage; reply != nil && reply.From != nil && reply.SenderChat == nil {
+		return h.handleUserStats(ctx, msg, reply.From.ID)
+	}
+
+	stats, err := h.repo.GetChatStats(ctx, msg.Chat.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения статистики чата: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Статистика чата:\n")
+	sb.WriteString(fmt.Sprintf("Сообщений: %d\n", stats.TotalMessages))
+	sb.WriteString(fmt.Sprintf("Обращений к боту: %d\n", stats.AddressedMessages))
+	sb.WriteString(fmt.Sprintf("Ответов бота: %d\n", stats.BotMessages))
+	sb.WriteString(fmt.Sprintf("Участников писало: %d", stats.Participants))
+	if !stats.FirstMessageAt.IsZero() {
+		sb.WriteString(fmt.Sprintf("\nПервое сообщение: %s", stats.FirstMessageAt.Format("02.01.2006 15:04")))
+		sb.WriteString(fmt.Sprintf("\nПоследнее сообщение: %s", stats.LastMessageAt.Format("02.01.2006 15:04")))
+	}
+	return h.reply(ctx, msg, sb.String())
+}
+
+func (h *WebhookHandler) handleUserStats(ctx context.Context, msg *Message, userID int64) error {
+	user, err := h.repo.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	if user == nil {
+		return h.reply(ctx, msg, "Про этого пользователя пока ничего не известно.")
+	}
+	stats, err := h.repo.GetUserStats(ctx, msg.Chat.ID, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения статистики пользователя: %w", err)
+	}
+	history, err := h.repo.GetUserNameHistory(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения истории имен: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Статистика пользователя %s:\n", formatUserName(user.FirstName, user.LastName, user.Username)))
+	sb.WriteString(fmt.Sprintf("Сообщений в этом чате: %d\n", stats.TotalMessages))
+	sb.WriteString(fmt.Sprintf("Обращений к боту: %d", stats.AddressedMessages))
+	if !stats.FirstMessageAt.IsZero() {
+		sb.WriteString(fmt.Sprintf("\nПервое сообщение: %s", stats.FirstMessageAt.Format("02.01.2006 15:04")))
+		sb.WriteString(fmt.Sprintf("\nПоследнее сообщение: %s", stats.LastMessageAt.Format("02.01.2006 15:04")))
+	}
+	if len(history) > 1 {
+		sb.WriteString("\nПрежние имена:")
+		for _, change := range history[:len(history)-1] {
+			sb.WriteString(fmt.Sprintf("\n%s - %s", change.SeenAt.Format("02.01.2006"),
+				formatUserName(change.FirstName, change.LastName, change.Username)))
+		}
+	}
+	return h.reply(ctx, msg, sb.String())
+}
+
+// formatUserName формирует имя пользователя вида "Имя Фамилия (@username)"
+func formatUserName(firstName, lastName, username string) string {
+	name := strings.TrimSpace(firstName + " " + lastName)
+	if username != "" {
+		if name == "" {
+			return "@" + username
+		}
+		name += " (@" + username + ")"
+	}
+	if name == "" {
+		return "без имени"
+	}
+	return name
+}
+
+// requireAdmin проверяет права администратора и сообщает пользователю об отказе
+func (h *WebhookHandler) requireAdmin(ctx context.Context, msg *Message) (bool, error) {
+	admin, err := h.isChatAdmin(ctx, msg)
+	if err != nil {
+		return false, err
+	}
+	if !admin {
+		return false, h.reply(ctx, msg, "Эта команда доступна только администраторам чата.")
+	}
+	return true, nil
+}
+
+// isChatAdmin проверяет, может ли автор сообщения менять настройки чата
+func (h *WebhookHandler) isChatAdmin(ctx context.Context, msg *Message) (bool, error) {
+	if msg.Chat.Type == "private" {
+		return true, nil
+	}
+	if msg.From == nil {
+		return false, nil
+	}
+
+	member, err := h.tgClient.GetChatMember(ctx, msg.Chat.ID, msg.From.ID)
+	if err != nil {
+		return false, err
+	}
+	return member.IsAdmin(), nil
+}
+
+// reply отправляет служебный ответ, который не попадает в историю диалога
+func (h *WebhookHandler) reply(ctx context.Context, msg *Message, text string) error {
+	if err := h.tgClient.SendServiceMessage(ctx, msg.Chat.ID, msg.topicID(), text, msg.MessageID); err != nil {
+		return fmt.Errorf("ошибка отправки ответа на команду: %w", err)
+	}
+	return nil
+}
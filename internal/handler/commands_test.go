@@ -0,0 +1,31 @@
+package handler
+
+import "testing"
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text            string
+		name, bot, args string
+		ok              bool
+	}{
+		{text: "/start", name: "start", ok: true},
+		{text: "/Search жорик пришел", name: "search", args: "жорик пришел", ok: true},
+		{text: "/params@SuetaBot max_tokens 100", name: "params", bot: "SuetaBot", args: "max_tokens 100", ok: true},
+		{text: "/persona set строка\nвторая строка", name: "persona", args: "set строка\nвторая строка", ok: true},
+		{text: "  /reset  ", ok: false},
+		{text: "/", ok: false},
+		{text: "/@SuetaBot", ok: false},
+		{text: "привет /start", ok: false},
+		{text: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			name, bot, args, ok := parseCommand(tt.text)
+			if ok != tt.ok || name != tt.name || bot != tt.bot || args != tt.args {
+				t.Errorf("parseCommand(%q) = %q, %q, %q, %t, ожидалось %q, %q, %q, %t",
+					tt.text, name, bot, args, ok, tt.name, tt.bot, tt.args, tt.ok)
+			}
+		})
+	}
+}
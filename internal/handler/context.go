@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// maxContextTokens ограничивает бюджет контекста, который можно задать командой
+const maxContextTokens = 100000
+
+const contextUsage = `Бюджет истории чата в контексте LLM:
+/context - показать текущие значения
+/context tokens <число|reset> - примерное число токенов истории
+/context ambient <0-1|reset> - доля бюджета на фоновый разговор (сообщения, не адресованные боту)`
+
+func (h *WebhookHandler) handleContextCommand(ctx context.Context, msg *Message, args string) error {
+	sub, value := cutWord(args)
+
+	if sub == "" || sub == "show" {
+		settings, err := h.effectiveSettings(ctx, msg)
+		if err != nil {
+			return err
+		}
+		return h.reply(ctx, msg, h.formatContextSettings(settings))
+	}
+
+	if (sub != "tokens" && sub != "ambient") || value == "" {
+		return h.reply(ctx, msg, contextUsage)
+	}
+	if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+		return err
+	}
+
+	settings, err := h.loadChatSettings(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return err
+	}
+
+	var answer string
+	switch {
+	case sub == "tokens" && value == "reset":
+		settings.ContextTokens = nil
+		answer = "Бюджет контекста сброшен на значение по умолчанию."
+	case sub == "tokens":
+		tokens, err := strconv.Atoi(value)
+		if err != nil || tokens < 1 || tokens > maxContextTokens {
+			return h.reply(ctx, msg, fmt.Sprintf("Бюджет должен быть целым числом от 1 до %d.", maxContextTokens))
+		}
+		settings.ContextTokens = &tokens
+		answer = fmt.Sprintf("Бюджет контекста изменен на %d токенов.", tokens)
+	case value == "reset":
+		settings.AmbientRatio = nil
+		answer = "Доля фонового разговора сброшена на значение по умолчанию."
+	default:
+		ratio, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return h.reply(ctx, msg, "Доля фонового разговора должна быть числом от 0 до 1.")
+		}
+		settings.AmbientRatio = &ratio
+		answer = fmt.Sprintf("Доля фонового разговора изменена на %g.", ratio)
+	}
+
+	if err := h.repo.SaveChatSettings(ctx, settings); err != nil {
+		return fmt.Errorf("ошибка сохранения настроек чата: %w", err)
+	}
+	log.Printf("Настройки чата %d (тема %d) изменены: /context %s %s", msg.Chat.ID, msg.topicID(), sub, value)
+	return h.reply(ctx, msg, answer)
+}
+
+// formatContextSettings показывает действующий бюджет контекста с пометкой, откуда взято значение
+func (h *WebhookHandler) formatContextSettings(settings *models.ChatSettings) string {
+	tokens := fmt.Sprintf("%d (по умолчанию)", h.cfg.ContextTokens)
+	if settings != nil && settings.ContextTokens != nil {
+		tokens = strconv.Itoa(*settings.ContextTokens)
+	}
+	ratio := fmt.Sprintf("%g (по умолчанию)", h.cfg.ContextAmbientRatio)
+	if settings != nil && settings.AmbientRatio != nil {
+		ratio = fmt.Sprintf("%g", *settings.AmbientRatio)
+	}
+	return "Бюджет контекста, токенов: " + tokens + "\nДоля фонового разговора: " + ratio +
+		fmt.Sprintf("\nКонтекстное окно модели, токенов: %d", h.llmClient.ContextWindow(chatModel(settings)))
+}
+
+// chatModel возвращает модель из настроек чата (пустая строка - модель по умолчанию)
+func chatModel(settings *models.ChatSettings) string {
+	if settings == nil {
+		return ""
+	}
+	return settings.Model
+}
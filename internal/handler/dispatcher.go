@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueFull возвращается, когда очередь воркера переполнена
+	ErrQueueFull = errors.New("очередь обработки переполнена")
+	// ErrDispatcherClosed возвращается после начала остановки диспетчера
+	ErrDispatcherClosed = errors.New("диспетчер остановлен")
+)
+
+// queuedUpdate - обновление в очереди вместе со временем постановки
+type queuedUpdate struct {
+	update   *TelegramUpdate
+	queuedAt time.Time
+}
+
+// Dispatcher обрабатывает обновления в пуле воркеров.
+// У каждого чата своя очередь: обновления одного чата обрабатываются строго по порядку и не больше
+// одного одновременно, а свободный воркер берет следующий чат, у которого есть обновления. Поэтому долгий
+// ответ в одном чате не задерживает другие чаты, пока есть свободные воркеры.
+type Dispatcher struct {
+	process   func(ctx context.Context, update *TelegramUpdate)
+	workers   int
+	queueSize int           // Емкость очереди одного чата
+	capacity  int           // Сколько обновлений всего может ждать обработки
+	timeout   time.Duration // Ограничение времени обработки одного обновления (0 - без ограничения)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// ready будит воркеры, когда у чата появляются обновления или начинается остановка
+	ready *sync.Cond
+	// chats - чаты, у которых есть обновления в очереди или в обработке
+	chats map[int64]*chatQueue
+	// runnable - чаты с обновлениями, ожидающие свободного воркера, в порядке поступления
+	runnable []*chatQueue
+	pending  int // Обновлений в очередях
+	closed   bool
+	// done закрывается в начале остановки, чтобы ожидающие места в очереди отправители не задерживали ее
+	done chan struct{}
+	// freed закрывается (и заменяется новым), когда воркер забирает обновление из очереди
+	freed chan struct{}
+
+	metrics dispatcherMetrics
+}
+
+// chatQueue - очередь обновлений одного чата
+type chatQueue struct {
+	chatID  int64
+	updates []queuedUpdate
+	// scheduled - чат ждет воркера в runnable или его обновление сейчас обрабатывается
+	scheduled bool
+}
+
+// dispatcherMetrics - счетчики для наблюдения за нагрузкой и обратным давлением
+type dispatcherMetrics struct {
+	enqueued       atomic.Int64
+	rejected       atomic.Int64
+	processed      atomic.Int64
+	timedOut       atomic.Int64
+	inFlight       atomic.Int64
+	waitNanos      atomic.Int64
+	processedNanos atomic.Int64
+}
+
+// DispatcherStats - снимок метрик диспетчера
+type DispatcherStats struct {
+	Workers        int
+	QueueCapacity  int
+	QueueDepth     int
+	Chats          int
+	InFlight       int64
+	Enqueued       int64
+	Rejected       int64
+	Processed      int64
+	TimedOut       int64
+	WaitTime       time.Duration
+	ProcessingTime time.Duration
+}
+
+// NewDispatcher запускает workers воркеров. В очереди одного чата может ждать до queueSize обновлений,
+// а всего - до workers * queueSize. timeout ограничивает обработку одного обновления (0 - без ограничения).
+func NewDispatcher(
+	workers, queueSize int,
+	timeout time.Duration,
+	process func(ctx context.Context, update *TelegramUpdate),
+) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		process:   process,
+		workers:   workers,
+		queueSize: queueSize,
+		capacity:  workers * queueSize,
+		timeout:   timeout,
+		ctx:       ctx,
+		cancel:    cancel,
+		chats:     make(map[int64]*chatQueue),
+		done:      make(chan struct{}),
+		freed:     make(chan struct{}),
+	}
+	d.ready = sync.NewCond(&d.mu)
+
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+
+	return d
+}
+
+// Submit ставит обновление в очередь без ожидания. Если очередь чата заполнена, возвращает ErrQueueFull.
+func (d *Dispatcher) Submit(update *TelegramUpdate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	if !d.enqueueLocked(update) {
+		d.metrics.rejected.Add(1)
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// SubmitWait ставит обновление в очередь, ожидая освобождения места, отмены ctx или остановки диспетчера.
+// Блокировка на время ожидания не удерживается, поэтому переполненная очередь не задерживает Shutdown.
+func (d *Dispatcher) SubmitWait(ctx context.Context, update *TelegramUpdate) error {
+	for {
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			return ErrDispatcherClosed
+		}
+		if d.enqueueLocked(update) {
+			d.mu.Unlock()
+			return nil
+		}
+		freed := d.freed
+		d.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-d.done:
+			return ErrDispatcherClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// enqueueLocked ставит обновление в очередь его чата, если в ней и в диспетчере есть место.
+// Вызывается под d.mu.
+func (d *Dispatcher) enqueueLocked(update *TelegramUpdate) bool {
+	chatID := update.chatID()
+	queue := d.chats[chatID]
+	if d.pending >= d.capacity || (queue != nil && len(queue.updates) >= d.queueSize) {
+		return false
+	}
+
+	if queue == nil {
+		queue = &chatQueue{chatID: chatID}
+		d.chats[chatID] = queue
+	}
+	queue.updates = append(queue.updates, queuedUpdate{update: update, queuedAt: time.Now()})
+	d.pending++
+	d.metrics.enqueued.Add(1)
+
+	if !queue.scheduled {
+		queue.scheduled = true
+		d.runnable = append(d.runnable, queue)
+		d.ready.Signal()
+	}
+	return true
+}
+
+// Shutdown прекращает прием обновлений и ждет, пока воркеры обработают уже принятые.
+// Если ctx истекает раньше, незавершенная обработка отменяется.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+		d.ready.Broadcast()
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return fmt.Errorf("не дождались завершения обработки (в очереди %d): %w", d.Stats().QueueDepth, ctx.Err())
+	}
+}
+
+// Stats возвращает текущие метрики диспетчера
+func (d *Dispatcher) Stats() DispatcherStats {
+	d.mu.Lock()
+	depth, chats := d.pending, len(d.chats)
+	d.mu.Unlock()
+
+	return DispatcherStats{
+		Workers:        d.workers,
+		QueueCapacity:  d.capacity,
+		QueueDepth:     depth,
+		Chats:          chats,
+		InFlight:       d.metrics.inFlight.Load(),
+		Enqueued:       d.metrics.enqueued.Load(),
+		Rejected:       d.metrics.rejected.Load(),
+		Processed:      d.metrics.processed.Load(),
+		TimedOut:       d.metrics.timedOut.Load(),
+		WaitTime:       time.Duration(d.metrics.waitNanos.Load()),
+		ProcessingTime: time.Duration(d.metrics.processedNanos.Load()),
+	}
+}
+
+// WriteMetrics выводит метрики в текстовом формате Prometheus
+func (s DispatcherStats) WriteMetrics(w io.Writer) error {
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"sueta_dispatcher_workers", "gauge", "Количество воркеров", float64(s.Workers)},
+		{"sueta_dispatcher_queue_capacity", "gauge", "Сколько обновлений всего может ждать в очередях", float64(s.QueueCapacity)},
+		{"sueta_dispatcher_queue_depth", "gauge", "Обновлений в очередях", float64(s.QueueDepth)},
+		{"sueta_dispatcher_chats", "gauge", "Чатов с обновлениями в очереди или в обработке", float64(s.Chats)},
+		{"sueta_dispatcher_in_flight", "gauge", "Обновлений в обработке", float64(s.InFlight)},
+		{"sueta_dispatcher_enqueued_total", "counter", "Принято обновлений", float64(s.Enqueued)},
+		{"sueta_dispatcher_rejected_total", "counter", "Отклонено из-за переполнения очереди", float64(s.Rejected)},
+		{"sueta_dispatcher_processed_total", "counter", "Обработано обновлений", float64(s.Processed)},
+		{"sueta_dispatcher_timeouts_total", "counter", "Обработка прервана по UPDATE_TIMEOUT", float64(s.TimedOut)},
+		{"sueta_dispatcher_wait_seconds_total", "counter", "Суммарное время ожидания в очереди", s.WaitTime.Seconds()},
+		{"sueta_dispatcher_processing_seconds_total", "counter", "Суммарное время обработки", s.ProcessingTime.Seconds()},
+	}
+
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n",
+			m.name, m.help, m.name, m.kind, m.name, m.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		queue, item, ok := d.next()
+		if !ok {
+			return
+		}
+
+		started := time.Now()
+		d.metrics.waitNanos.Add(int64(started.Sub(item.queuedAt)))
+
+		d.processSafely(item.update)
+
+		d.metrics.inFlight.Add(-1)
+		d.metrics.processed.Add(1)
+		d.metrics.processedNanos.Add(int64(time.Since(started)))
+		d.release(queue)
+	}
+}
+
+// next ждет чат с обновлениями и забирает первое обновление из его очереди.
+// Возвращает false, когда диспетчер остановлен и ждущих воркера чатов не осталось.
+func (d *Dispatcher) next() (*chatQueue, queuedUpdate, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.runnable) == 0 {
+		if d.closed {
+			return nil, queuedUpdate{}, false
+		}
+		d.ready.Wait()
+	}
+
+	queue := d.runnable[0]
+	d.runnable[0] = nil
+	d.runnable = d.runnable[1:]
+	item := queue.updates[0]
+	queue.updates[0] = queuedUpdate{}
+	queue.updates = queue.updates[1:]
+	d.pending--
+	d.metrics.inFlight.Add(1)
+
+	// Будим отправителей, ожидающих места в очереди
+	close(d.freed)
+	d.freed = make(chan struct{})
+	return queue, item, true
+}
+
+// release вызывается после обработки обновления чата: если у чата остались обновления,
+// он встает в конец очереди воркеров, чтобы остальные чаты не ждали, пока он разберет свою очередь
+func (d *Dispatcher) release(queue *chatQueue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(queue.updates) > 0 {
+		d.runnable = append(d.runnable, queue)
+		d.ready.Signal()
+		return
+	}
+	queue.scheduled = false
+	delete(d.chats, queue.chatID)
+}
+
+// processSafely не дает панике в обработке одного обновления остановить воркер
+// и ограничивает время обработки
+func (d *Dispatcher) processSafely(update *TelegramUpdate) {
+	ctx := d.ctx
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(d.ctx, d.timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Паника при обработке update %d: %v", update.UpdateID, r)
+		}
+	}()
+	d.process(ctx, update)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		d.metrics.timedOut.Add(1)
+		log.Printf("Обработка update %d прервана: превышено время %s", update.UpdateID, d.timeout)
+	}
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func chatUpdate(updateID int, chatID int64) *TelegramUpdate {
+	return &TelegramUpdate{UpdateID: updateID, Message: &Message{Chat: &Chat{ID: chatID}}}
+}
+
+// waitFor ждет выполнения условия, проверяя его до истечения секунды
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[int64][]int)
+	d := NewDispatcher(4, 100, 0, func(_ context.Context, update *TelegramUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		chatID := update.chatID()
+		processed[chatID] = append(processed[chatID], update.UpdateID)
+	})
+
+	chats := []int64{1, 2, 3, -100500}
+	for i := 0; i < 50; i++ {
+		if err := d.SubmitWait(context.Background(), chatUpdate(i, chats[i%len(chats)])); err != nil {
+			t.Fatalf("SubmitWait: %v", err)
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for _, chatID := range chats {
+		updates := processed[chatID]
+		if len(updates) == 0 {
+			t.Errorf("обновления чата %d не обработаны", chatID)
+		}
+		for i := 1; i < len(updates); i++ {
+			if updates[i] < updates[i-1] {
+				t.Errorf("обновления чата %d обработаны не по порядку: %v", chatID, updates)
+				break
+			}
+		}
+	}
+	if stats := d.Stats(); stats.Processed != 50 || stats.Enqueued != 50 {
+		t.Errorf("метрики: %+v", stats)
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	d := NewDispatcher(1, 1, 0, func(ctx context.Context, _ *TelegramUpdate) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+
+	// Первое обновление занимает воркер, второе - очередь
+	if err := d.Submit(chatUpdate(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "воркер взял обновление", func() bool { return d.Stats().InFlight == 1 })
+	if err := d.Submit(chatUpdate(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Submit(chatUpdate(3, 1)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit в полную очередь = %v, ожидалась ErrQueueFull", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.SubmitWait(ctx, chatUpdate(3, 1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SubmitWait в полную очередь = %v, ожидалось истечение ctx", err)
+	}
+
+	close(release)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Submit(chatUpdate(4, 1)); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("Submit после остановки = %v, ожидалась ErrDispatcherClosed", err)
+	}
+}
+
+func TestDispatcherShutdownReleasesWaitingSenders(t *testing.T) {
+	release := make(chan struct{})
+	d := NewDispatcher(1, 1, 0, func(ctx context.Context, _ *TelegramUpdate) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	})
+
+	if err := d.Submit(chatUpdate(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "воркер взял обновление", func() bool { return d.Stats().InFlight == 1 })
+	if err := d.Submit(chatUpdate(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отправитель ждет места в полной очереди без ограничения по времени
+	sent := make(chan error, 1)
+	go func() { sent <- d.SubmitWait(context.Background(), chatUpdate(3, 1)) }()
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Shutdown(context.Background()) }()
+
+	// Остановка не ждет освобождения очереди: отправитель сразу получает отказ
+	select {
+	case err := <-sent:
+		if !errors.Is(err, ErrDispatcherClosed) {
+			t.Errorf("SubmitWait во время остановки = %v, ожидалась ErrDispatcherClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SubmitWait не завершился после начала остановки")
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if processed := d.Stats().Processed; processed != 2 {
+		t.Errorf("обработано %d обновлений, ожидалось 2", processed)
+	}
+}
+
+func TestDispatcherShutdownTimeout(t *testing.T) {
+	d := NewDispatcher(1, 1, 0, func(ctx context.Context, _ *TelegramUpdate) {
+		<-ctx.Done()
+	})
+	if err := d.Submit(chatUpdate(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, ожидалось истечение ctx", err)
+	}
+}
+
+func TestDispatcherSlowChatDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var processed []int
+	var running, maxRunning int
+	d := NewDispatcher(2, 10, 0, func(ctx context.Context, update *TelegramUpdate) {
+		mu.Lock()
+		if update.chatID() == 1 {
+			running++
+			maxRunning = max(maxRunning, running)
+		}
+		mu.Unlock()
+
+		if update.chatID() == 1 {
+			<-release
+		}
+
+		mu.Lock()
+		if update.chatID() == 1 {
+			running--
+		}
+		processed = append(processed, update.UpdateID)
+		mu.Unlock()
+	})
+
+	// Чаты 1 и 3 при разделении по chatID % 2 попали бы к одному воркеру
+	for i, chatID := range []int64{1, 1, 3, 3, 3} {
+		if err := d.Submit(chatUpdate(i+1, chatID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "обработка чата 3", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(processed) == 3
+	})
+
+	close(release)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning != 1 {
+		t.Errorf("обновления одного чата обрабатывались параллельно: %d одновременно", maxRunning)
+	}
+	if stats := d.Stats(); stats.Processed != 5 || stats.Chats != 0 || stats.QueueDepth != 0 {
+		t.Errorf("метрики: %+v", stats)
+	}
+}
+
+func TestDispatcherUpdateTimeout(t *testing.T) {
+	d := NewDispatcher(1, 1, 10*time.Millisecond, func(ctx context.Context, _ *TelegramUpdate) {
+		<-ctx.Done()
+	})
+	if err := d.Submit(chatUpdate(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "прерывание обработки по таймауту", func() bool { return d.Stats().Processed == 1 })
+
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if timedOut := d.Stats().TimedOut; timedOut != 1 {
+		t.Errorf("прервано по таймауту %d, ожидалось 1", timedOut)
+	}
+}
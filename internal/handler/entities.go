@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Типы сущностей сообщения, которые влияют на адресацию
+const (
+	EntityMention     = "mention"
+	EntityTextMention = "text_mention"
+	EntityBotCommand  = "bot_command"
+)
+
+// MessageEntity представляет специальную сущность в тексте сообщения (упоминание, команду и т.п.)
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url,omitempty"`
+	User   *User  `json:"user,omitempty"`
+}
+
+// entityText возвращает фрагмент текста, занятый сущностью.
+// Telegram считает offset и length в UTF-16 code units, а не в байтах или рунах.
+func entityText(text string, entity MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	start, end := entity.Offset, entity.Offset+entity.Length
+	if start < 0 || end > len(units) || start >= end {
+		return ""
+	}
+	return string(utf16.Decode(units[start:end]))
+}
+
+// mentionsBot проверяет, упоминается ли бот в сущностях текста или подписи к медиа:
+// @username, text_mention с ID бота или команда вида /command@username
+func (h *WebhookHandler) mentionsBot(msg *Message) bool {
+	if h.me == nil {
+		return false
+	}
+	return h.entitiesMentionBot(msg.Text, msg.Entities) || h.entitiesMentionBot(msg.Caption, msg.CaptionEntities)
+}
+
+// entitiesMentionBot проверяет сущности entities, размеченные в тексте text
+func (h *WebhookHandler) entitiesMentionBot(text string, entities []MessageEntity) bool {
+	for _, entity := range entities {
+		switch entity.Type {
+		case EntityMention:
+			if h.isOwnUsername(strings.TrimPrefix(entityText(text, entity), "@")) {
+				return true
+			}
+		case EntityTextMention:
+			if entity.User != nil && entity.User.ID == h.me.ID {
+				return true
+			}
+		case EntityBotCommand:
+			_, target, _ := strings.Cut(entityText(text, entity), "@")
+			if h.isOwnUsername(target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isOwnUsername сравнивает username без учета регистра, как это делает Telegram
+func (h *WebhookHandler) isOwnUsername(username string) bool {
+	return username != "" && h.me != nil && strings.EqualFold(username, h.me.Username)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/semyon-ancherbak/sueta/internal/telegram"
+)
+
+func TestMentionsBot(t *testing.T) {
+	h := &WebhookHandler{me: &telegram.User{ID: 999, Username: "sueta_bot"}}
+
+	tests := []struct {
+		name string
+		msg  *Message
+		want bool
+	}{
+		{
+			name: "упоминание в тексте",
+			msg: &Message{
+				Text:     "привет @Sueta_Bot",
+				Entities: []MessageEntity{{Type: EntityMention, Offset: 7, Length: 10}},
+			},
+			want: true,
+		},
+		{
+			name: "упоминание другого бота",
+			msg: &Message{
+				Text:     "привет @other_bot",
+				Entities: []MessageEntity{{Type: EntityMention, Offset: 7, Length: 10}},
+			},
+		},
+		{
+			name: "смещение в UTF-16 после эмодзи",
+			msg: &Message{
+				Text:     "😀 @sueta_bot",
+				Entities: []MessageEntity{{Type: EntityMention, Offset: 3, Length: 10}},
+			},
+			want: true,
+		},
+		{
+			name: "text_mention",
+			msg: &Message{
+				Text:     "Жорик",
+				Entities: []MessageEntity{{Type: EntityTextMention, Offset: 0, Length: 5, User: &User{ID: 999}}},
+			},
+			want: true,
+		},
+		{
+			name: "команда с username бота",
+			msg: &Message{
+				Text:     "/stats@sueta_bot",
+				Entities: []MessageEntity{{Type: EntityBotCommand, Offset: 0, Length: 16}},
+			},
+			want: true,
+		},
+		{
+			name: "упоминание в подписи к фото",
+			msg: &Message{
+				Caption:         "🐱 смотри, @sueta_bot",
+				CaptionEntities: []MessageEntity{{Type: EntityMention, Offset: 11, Length: 10}},
+			},
+			want: true,
+		},
+		{
+			name: "сущности подписи не относятся к тексту",
+			msg: &Message{
+				Text:            "@sueta_bot",
+				CaptionEntities: []MessageEntity{{Type: EntityMention, Offset: 0, Length: 10}},
+			},
+		},
+		{
+			name: "смещение за пределами текста",
+			msg: &Message{
+				Caption:         "@sueta",
+				CaptionEntities: []MessageEntity{{Type: EntityMention, Offset: 0, Length: 10}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.mentionsBot(tt.msg); got != tt.want {
+				t.Errorf("mentionsBot = %t, ожидалось %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+const paramsUsage = `Параметры генерации LLM в этом чате:
+/params - показать действующие значения
+/params <параметр> <значение|reset> - изменить параметр
+/params reset - сбросить все параметры на значения по умолчанию
+
+Параметры: temperature (0-2), top_p (0-1), max_tokens, stop (до 4 строк через |, \n - перевод строки),
+presence_penalty и frequency_penalty (от -2 до 2), seed`
+
+func (h *WebhookHandler) handleParamsCommand(ctx context.Context, msg *Message, args string) error {
+	name, value := cutWord(args)
+	name = strings.ToLower(name)
+
+	if name == "" || name == "show" {
+		settings, err := h.effectiveSettings(ctx, msg)
+		if err != nil {
+			return err
+		}
+		return h.reply(ctx, msg, h.formatSamplingParams(settings))
+	}
+
+	if name != "reset" && (!isSamplingParam(name) || value == "") {
+		return h.reply(ctx, msg, paramsUsage)
+	}
+	if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+		return err
+	}
+
+	settings, err := h.loadChatSettings(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return err
+	}
+
+	var answer string
+	if name == "reset" {
+		settings.SamplingParams = models.SamplingParams{}
+		answer = "Параметры генерации сброшены на значения по умолчанию."
+	} else {
+		params := settings.SamplingParams
+		if err := setSamplingParam(&params, name, value); err != nil {
+			return h.reply(ctx, msg, err.Error())
+		}
+		if err := params.Validate(); err != nil {
+			return h.reply(ctx, msg, "Некорректное значение: "+err.Error()+".")
+		}
+		if params.MaxTokens != nil {
+			// Ответ должен помещаться в контекстное окно вместе с запросом
+			effective, err := h.effectiveSettings(ctx, msg)
+			if err != nil {
+				return err
+			}
+			if window := h.llmClient.ContextWindow(chatModel(effective)); *params.MaxTokens >= window {
+				return h.reply(ctx, msg, fmt.Sprintf(
+					"max_tokens должен быть меньше контекстного окна модели (%d токенов).", window))
+			}
+		}
+		settings.SamplingParams = params
+		answer = fmt.Sprintf("Параметр %s изменен.", name)
+		if value == "reset" {
+			answer = fmt.Sprintf("Параметр %s сброшен на значение по умолчанию.", name)
+		}
+	}
+
+	if err := h.repo.SaveChatSettings(ctx, settings); err != nil {
+		return fmt.Errorf("ошибка сохранения настроек чата: %w", err)
+	}
+	log.Printf("Параметры генерации чата %d (тема %d) изменены: /params %s", msg.Chat.ID, msg.topicID(), args)
+	return h.reply(ctx, msg, answer)
+}
+
+func isSamplingParam(name string) bool {
+	for _, known := range models.SamplingParamNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// setSamplingParam задает параметр name из текста value ("reset" - сбросить параметр)
+func setSamplingParam(params *models.SamplingParams, name, value string) error {
+	switch name {
+	case "temperature":
+		return setFloatParam(&params.Temperature, name, value)
+	case "top_p":
+		return setFloatParam(&params.TopP, name, value)
+	case "max_tokens":
+		return setIntParam(&params.MaxTokens, name, value)
+	case "stop":
+		params.Stop = nil
+		if value != "reset" {
+			params.Stop = models.ParseStopSequences(value)
+		}
+	case "presence_penalty":
+		return setFloatParam(&params.PresencePenalty, name, value)
+	case "frequency_penalty":
+		return setFloatParam(&params.FrequencyPenalty, name, value)
+	case "seed":
+		return setIntParam(&params.Seed, name, value)
+	}
+	return nil
+}
+
+func setFloatParam(target **float64, name, value string) error {
+	if value == "reset" {
+		*target = nil
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return fmt.Errorf("%s должен быть числом", name)
+	}
+	*target = &parsed
+	return nil
+}
+
+func setIntParam(target **int, name, value string) error {
+	if value == "reset" {
+		*target = nil
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s должен быть целым числом", name)
+	}
+	*target = &parsed
+	return nil
+}
+
+// formatSamplingParams показывает действующие параметры: заданные для чата и глобальные из конфигурации
+func (h *WebhookHandler) formatSamplingParams(settings *models.ChatSettings) string {
+	var chat models.SamplingParams
+	if settings != nil {
+		chat = settings.SamplingParams
+	}
+	global := h.cfg.LLMSampling
+
+	var sb strings.Builder
+	sb.WriteString("Параметры генерации:")
+	for _, name := range models.SamplingParamNames {
+		value, source := "не задан", ""
+		if v := chat.Value(name); v != "" {
+			value = v
+		} else if v := global.Value(name); v != "" {
+			value, source = v, " (по умолчанию)"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s: %s%s", name, value, source))
+	}
+	return sb.String()
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/semyon-ancherbak/sueta/internal/llm"
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// maxPersonaShowLength - сколько символов персоны показывать в /persona show (лимит Telegram - 4096)
+const maxPersonaShowLength = 3500
+
+const personaUsage = `Управление персоной бота в этом чате:
+/persona show - показать текущие настройки
+/persona set <текст> - задать собственный системный промпт
+/persona reset - вернуть все настройки по умолчанию`
+
+func (h *WebhookHandler) handlePersonaCommand(ctx context.Context, msg *Message, args string) error {
+	sub, value := cutWord(args)
+
+	if sub == "" || sub == "show" {
+		settings, err := h.effectiveSettings(ctx, msg)
+		if err != nil {
+			return err
+		}
+		return h.reply(ctx, msg, formatPersona(settings))
+	}
+
+	if sub != "set" && sub != "reset" {
+		return h.reply(ctx, msg, personaUsage)
+	}
+	if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+		return err
+	}
+
+	settings, err := h.loadChatSettings(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return err
+	}
+
+	var answer string
+	switch sub {
+	case "set":
+		if value == "" {
+			return h.reply(ctx, msg, "Укажите текст персоны: /persona set <текст>")
+		}
+		if err := llm.ValidatePrompt(value); err != nil {
+			return h.reply(ctx, msg, fmt.Sprintf("Ошибка в шаблоне персоны: %v", err))
+		}
+		settings.PersonaPrompt = value
+		answer = "Персона обновлена."
+	case "reset":
+		if err := h.repo.DeleteChatSettings(ctx, msg.Chat.ID, msg.topicID()); err != nil {
+			return fmt.Errorf("ошибка сброса настроек чата: %w", err)
+		}
+		log.Printf("Настройки чата %d (тема %d) сброшены", msg.Chat.ID, msg.topicID())
+		return h.reply(ctx, msg, "Персона и настройки сброшены на значения по умолчанию.")
+	default:
+		return h.reply(ctx, msg, personaUsage)
+	}
+
+	if err := h.repo.SaveChatSettings(ctx, settings); err != nil {
+		return fmt.Errorf("ошибка сохранения настроек чата: %w", err)
+	}
+	log.Printf("Настройки чата %d (тема %d) изменены: /persona %s", msg.Chat.ID, msg.topicID(), sub)
+	return h.reply(ctx, msg, answer)
+}
+
+func (h *WebhookHandler) handleModelCommand(ctx context.Context, msg *Message, args string) error {
+	if args == "" {
+		settings, err := h.effectiveSettings(ctx, msg)
+		if err != nil {
+			return err
+		}
+		if settings == nil || settings.Model == "" {
+			return h.reply(ctx, msg, "Модель: по умолчанию")
+		}
+		return h.reply(ctx, msg, "Модель: "+settings.Model)
+	}
+
+	if strings.ContainsFunc(args, unicode.IsSpace) {
+		return h.reply(ctx, msg, "Название модели не должно содержать пробелов.")
+	}
+	if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+		return err
+	}
+
+	settings, err := h.loadChatSettings(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return err
+	}
+
+	answer := fmt.Sprintf("Модель изменена на %s.", args)
+	if args == "reset" {
+		settings.Model = ""
+		answer = "Модель сброшена на значение по умолчанию."
+	} else {
+		settings.Model = args
+	}
+
+	if err := h.repo.SaveChatSettings(ctx, settings); err != nil {
+		return fmt.Errorf("ошибка сохранения настроек чата: %w", err)
+	}
+	log.Printf("Модель чата %d (тема %d) изменена: %q", msg.Chat.ID, msg.topicID(), settings.Model)
+	return h.reply(ctx, msg, answer)
+}
+
+// loadChatSettings возвращает настройки чата или темы форума для изменения (пустые, если еще не заданы).
+// Команды в теме меняют настройки только этой темы, в общей теме - всего чата.
+func (h *WebhookHandler) loadChatSettings(ctx context.Context, chatID int64, topicID int) (*models.ChatSettings, error) {
+	settings, err := h.repo.GetChatSettings(ctx, chatID, topicID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек чата: %w", err)
+	}
+	if settings == nil {
+		settings = &models.ChatSettings{ChatID: chatID, TopicID: topicID}
+	}
+	return settings, nil
+}
+
+// effectiveSettings возвращает настройки, действующие для сообщения: настройки темы форума
+// поверх настроек всего чата (nil, если ничего не задано)
+func (h *WebhookHandler) effectiveSettings(ctx context.Context, msg *Message) (*models.ChatSettings, error) {
+	settings, err := h.repo.GetChatSettings(ctx, msg.Chat.ID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек чата: %w", err)
+	}
+	if msg.topicID() == 0 {
+		return settings, nil
+	}
+
+	topicSettings, err := h.repo.GetChatSettings(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения настроек темы: %w", err)
+	}
+	return models.MergeChatSettings(settings, topicSettings), nil
+}
+
+func formatPersona(settings *models.ChatSettings) string {
+	var sb strings.Builder
+
+	if settings == nil || settings.PersonaPrompt == "" {
+		sb.WriteString("Персона: по умолчанию\n")
+	} else {
+		persona := settings.PersonaPrompt
+		if utf8.RuneCountInString(persona) > maxPersonaShowLength {
+			persona = string([]rune(persona)[:maxPersonaShowLength]) + "…"
+		}
+		sb.WriteString("Персона:\n" + persona + "\n")
+	}
+
+	if settings != nil && settings.Model != "" {
+		sb.WriteString("Модель: " + settings.Model)
+	} else {
+		sb.WriteString("Модель: по умолчанию")
+	}
+
+	return sb.String()
+}
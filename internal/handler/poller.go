@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/telegram"
+)
+
+const (
+	pollerMinBackoff = time.Second
+	pollerMaxBackoff = 30 * time.Second
+)
+
+// Poller получает обновления через getUpdates и передает их в тот же конвейер, что и webhook
+type Poller struct {
+	handler *WebhookHandler
+	timeout int
+}
+
+func NewPoller(handler *WebhookHandler, timeout int) *Poller {
+	return &Poller{
+		handler: handler,
+		timeout: timeout,
+	}
+}
+
+// Run опрашивает Telegram до отмены ctx. Offset сохраняется в базе после каждой пачки обновлений,
+// поэтому после перезапуска бот продолжает с того же места.
+func (p *Poller) Run(ctx context.Context) error {
+	offset, err := p.handler.repo.GetUpdateOffset(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Long polling запущен, offset=%d", offset)
+
+	backoff := pollerMinBackoff
+	for {
+		if ctx.Err() != nil {
+			log.Println("Long polling остановлен")
+			return nil
+		}
+
+		updates, err := p.handler.tgClient.GetUpdates(ctx, telegram.GetUpdatesRequest{
+			Offset:         offset,
+			Timeout:        p.timeout,
+			AllowedUpdates: AllowedUpdates,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			log.Printf("Ошибка long polling, повтор через %s: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, pollerMaxBackoff)
+			continue
+		}
+		backoff = pollerMinBackoff
+
+		if len(updates) == 0 {
+			continue
+		}
+
+		for _, raw := range updates {
+			var update TelegramUpdate
+			err := json.Unmarshal(raw, &update)
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+			}
+			if err != nil {
+				log.Printf("Ошибка парсинга обновления: %v", err)
+				continue
+			}
+			// Ожидание свободного места в очереди замедляет опрос - это и есть обратное давление
+			if err := p.handler.dispatcher.SubmitWait(ctx, &update); err != nil {
+				log.Printf("Update %d не принят в обработку: %v", update.UpdateID, err)
+				offset = update.UpdateID
+				break
+			}
+		}
+
+		// Сохраняем offset без ctx: принятые обновления будут обработаны до остановки,
+		// поэтому повторно получать их не нужно
+		if err := p.handler.repo.SaveUpdateOffset(context.Background(), offset); err != nil {
+			log.Printf("Ошибка сохранения offset: %v", err)
+		}
+	}
+}
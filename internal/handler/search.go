@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// searchResultsLimit - сколько найденных сообщений показывать в ответе на /search
+const searchResultsLimit = 10
+
+func (h *WebhookHandler) handleSearchCommand(ctx context.Context, msg *Message, args string) error {
+	if args == "" {
+		return h.reply(ctx, msg, "Укажите, что искать: /search <слова>")
+	}
+
+	results, err := h.repo.SearchMessages(ctx, msg.Chat.ID, args, searchResultsLimit)
+	if err != nil {
+		return fmt.Errorf("ошибка поиска сообщений: %w", err)
+	}
+	if len(results) == 0 {
+		return h.reply(ctx, msg, "Ничего не найдено.")
+	}
+	return h.reply(ctx, msg, formatSearchResults(results))
+}
+
+func formatSearchResults(results []*models.SearchResult) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Найдено сообщений: %d\n", len(results)))
+	for _, result := range results {
+		m := result.Message
+		author := m.FirstName
+		if author == "" {
+			author = m.Username
+		}
+		if author == "" {
+			author = "Пользователь"
+		}
+		snippet := strings.Join(strings.Fields(result.Snippet), " ")
+		sb.WriteString(fmt.Sprintf("\n%s %s: %s", m.Date.Format("02.01.2006 15:04"), author, snippet))
+	}
+	return sb.String()
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/llm"
+	"github.com/semyon-ancherbak/sueta/internal/telegram"
+)
+
+const (
+	// streamCursor дописывается к ответу, пока он генерируется; заглушка до первого фрагмента состоит только из него
+	streamCursor = "…"
+	// streamInterrupted дописывается к ответу, генерация которого оборвалась
+	streamInterrupted = "\n\n(ответ прерван)"
+	// streamCleanupTimeout - сколько ждать Telegram при завершении ответа, если обработка обновления уже отменена
+	streamCleanupTimeout = 10 * time.Second
+)
+
+// streamBotReply показывает ответ LLM по мере генерации: отправляет заглушку в ответ на msg
+// и дописывает ее, а окончательный текст сохраняет в истории
+func (h *WebhookHandler) streamBotReply(
+	ctx context.Context,
+	msg *Message,
+	streamer llm.StreamGenerator,
+	req llm.GenerateRequest,
+) error {
+	reply := &streamReply{
+		client:   h.tgClient,
+		chatID:   msg.Chat.ID,
+		topicID:  msg.topicID(),
+		interval: h.cfg.LLMStreamEditInterval,
+	}
+	if err := reply.start(ctx, msg.MessageID); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения в Telegram: %w", err)
+	}
+
+	response, err := streamer.StreamResponse(ctx, req, func(delta string) error {
+		reply.write(ctx, delta)
+		return nil
+	})
+
+	// Отмена обработки не должна оставить в чате заглушку: доводим ответ до конца с отдельным таймаутом
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), streamCleanupTimeout)
+	defer cancel()
+
+	if err != nil {
+		if abortErr := reply.abort(cleanupCtx); abortErr != nil {
+			log.Printf("Ошибка завершения прерванного ответа: %v", abortErr)
+		}
+		return fmt.Errorf("ошибка генерации ответа: %w", err)
+	}
+	if strings.TrimSpace(response) == "" {
+		if abortErr := reply.abort(cleanupCtx); abortErr != nil {
+			log.Printf("Ошибка удаления заглушки: %v", abortErr)
+		}
+		return fmt.Errorf("LLM вернул пустой ответ")
+	}
+
+	log.Printf("LLM ответ: %s", response)
+
+	if err := reply.finish(cleanupCtx); err != nil {
+		return fmt.Errorf("ошибка отправки сообщения в Telegram: %w", err)
+	}
+	log.Printf("Ответ отправлен в чат %d", msg.Chat.ID)
+
+	return nil
+}
+
+// streamReply - ответ бота, который дописывается по мере генерации.
+// Текст обновляется через editMessageText не чаще interval, чтобы не упираться в лимиты Telegram.
+// Если ответ не помещается в одно сообщение, готовая часть сохраняется,
+// а продолжение пишется в новое сообщение.
+type streamReply struct {
+	client   *telegram.Client
+	chatID   int64
+	topicID  int
+	interval time.Duration
+
+	message  *telegram.Message // Сообщение, которое сейчас дописывается
+	text     strings.Builder   // Текст этого сообщения
+	written  bool              // Хотя бы один фрагмент ответа получен
+	shown    string            // Текст, который сейчас показан в Telegram
+	nextEdit time.Time         // Раньше этого времени сообщение не обновляем
+}
+
+// start отправляет заглушку, которая станет первым сообщением ответа
+func (r *streamReply) start(ctx context.Context, replyToMessageID int) error {
+	message, err := r.client.SendPlaceholder(ctx, r.chatID, r.topicID, streamCursor, replyToMessageID)
+	if err != nil {
+		return err
+	}
+	r.message = message
+	r.shown = streamCursor
+	r.nextEdit = time.Now().Add(r.interval)
+	return nil
+}
+
+// write добавляет фрагмент ответа и, если пора, показывает его.
+// Ошибки Telegram только логируются: генерация от них не прерывается, окончательный текст отправит finish.
+func (r *streamReply) write(ctx context.Context, delta string) {
+	r.written = true
+	r.text.WriteString(delta)
+
+	// Ответ перерос сообщение: завершаем его и продолжаем в новом
+	limit := telegram.MaxMessageLength - telegram.TextLength(streamCursor)
+	for telegram.TextLength(r.text.String()) > limit {
+		head, rest := splitMessageText(r.text.String(), limit)
+		if err := r.complete(ctx, head); err != nil {
+			log.Printf("Ошибка завершения части ответа: %v", err)
+		}
+		message, err := r.client.SendPlaceholder(ctx, r.chatID, r.topicID, streamCursor, 0)
+		if err != nil {
+			log.Printf("Ошибка отправки продолжения ответа: %v", err)
+			return
+		}
+		r.message = message
+		r.shown = streamCursor
+		r.text.Reset()
+		r.text.WriteString(rest)
+		r.nextEdit = time.Now().Add(r.interval)
+	}
+
+	if time.Now().Before(r.nextEdit) {
+		return
+	}
+	if err := r.edit(ctx, r.text.String()+streamCursor); err != nil {
+		log.Printf("Ошибка обновления ответа: %v", err)
+	}
+}
+
+// finish показывает окончательный текст ответа и сохраняет его в истории
+func (r *streamReply) finish(ctx context.Context) error {
+	return r.complete(ctx, r.text.String())
+}
+
+// abort завершает ответ после ошибки генерации: показанное начало ответа помечается прерванным
+// и сохраняется, а заглушка без текста удаляется
+func (r *streamReply) abort(ctx context.Context) error {
+	text := r.text.String()
+	if strings.TrimSpace(text) == "" {
+		if r.written {
+			// Начало ответа уже в предыдущих сообщениях, текущее содержит только курсор
+			return r.edit(ctx, strings.TrimSuffix(r.shown, streamCursor)+streamInterrupted)
+		}
+		return r.client.DeleteMessage(ctx, r.chatID, r.message.MessageID)
+	}
+
+	if err := r.edit(ctx, text+streamInterrupted); err != nil {
+		log.Printf("Ошибка обновления прерванного ответа: %v", err)
+	}
+	return r.client.SaveBotMessage(ctx, r.message, text)
+}
+
+// complete показывает в текущем сообщении окончательный текст и сохраняет его в истории.
+// Сообщение сохраняется, даже если обновить его не удалось: бот уже начал этот ответ.
+func (r *streamReply) complete(ctx context.Context, text string) error {
+	editErr := r.edit(ctx, text)
+	var apiErr *telegram.Error
+	if errors.As(editErr, &apiErr) && apiErr.RetryAfter > 0 && apiErr.RetryAfter <= streamCleanupTimeout {
+		// Окончательный текст терять нельзя: выжидаем лимит Telegram и пробуем еще раз
+		select {
+		case <-ctx.Done():
+		case <-time.After(apiErr.RetryAfter):
+			editErr = r.edit(ctx, text)
+		}
+	}
+
+	if err := r.client.SaveBotMessage(ctx, r.message, text); err != nil {
+		log.Printf("Ошибка сохранения сообщения бота: %v", err)
+	}
+	return editErr
+}
+
+// edit заменяет текст текущего сообщения, если он изменился
+func (r *streamReply) edit(ctx context.Context, text string) error {
+	if text == r.shown {
+		return nil
+	}
+	err := r.client.EditMessageText(ctx, r.chatID, r.message.MessageID, text)
+	r.nextEdit = time.Now().Add(r.interval)
+
+	var apiErr *telegram.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		// Telegram просит подождать - следующие обновления откладываем
+		r.nextEdit = time.Now().Add(apiErr.RetryAfter)
+	}
+	if err != nil {
+		return err
+	}
+	r.shown = text
+	return nil
+}
+
+// splitMessageText делит текст на начало длиной не больше limit (в единицах UTF-16) и остаток.
+// Граница по возможности приходится на перевод строки или пробел во второй половине начала.
+func splitMessageText(text string, limit int) (string, string) {
+	cut, length := len(text), 0
+	for i, r := range text {
+		length += telegram.TextLength(string(r))
+		if length > limit {
+			cut = i
+			break
+		}
+	}
+	if cut == len(text) {
+		return text, ""
+	}
+
+	head := text[:cut]
+	for _, sep := range []string{"\n", " "} {
+		if i := strings.LastIndex(head, sep); i >= len(head)/2 {
+			return text[:i], strings.TrimLeft(text[i:], " \n")
+		}
+	}
+	return head, text[cut:]
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/semyon-ancherbak/sueta/internal/trigger"
+)
+
+// maxChatTriggers - ограничение на количество слов-триггеров в одном чате
+const maxChatTriggers = 50
+
+const triggersUsage = `Слова, на которые бот откликается в этом чате (помимо своего имени):
+/triggers list - показать список
+/triggers add <слово> - добавить слово или словосочетание
+/triggers remove <слово> - удалить`
+
+func (h *WebhookHandler) handleTriggersCommand(ctx context.Context, msg *Message, args string) error {
+	sub, value := cutWord(args)
+
+	if sub == "" || sub == "list" {
+		words, err := h.repo.GetChatTriggers(ctx, msg.Chat.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка получения слов-триггеров: %w", err)
+		}
+		return h.reply(ctx, msg, formatTriggers(h.cfg.BotName, h.cfg.BotAliases, words))
+	}
+
+	if sub != "add" && sub != "remove" {
+		return h.reply(ctx, msg, triggersUsage)
+	}
+
+	if ok, err := h.requireAdmin(ctx, msg); err != nil || !ok {
+		return err
+	}
+
+	// Храним слово в нормализованном виде, чтобы "Шеф" и "шеф" не дублировались
+	word := strings.Join(trigger.Tokenize(value), " ")
+	if word == "" {
+		return h.reply(ctx, msg, triggersUsage)
+	}
+
+	if sub == "remove" {
+		removed, err := h.repo.DeleteChatTrigger(ctx, msg.Chat.ID, word)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления слова-триггера: %w", err)
+		}
+		if !removed {
+			return h.reply(ctx, msg, fmt.Sprintf("Слова «%s» нет в списке.", word))
+		}
+		log.Printf("Из чата %d удалено слово-триггер %q", msg.Chat.ID, word)
+		return h.reply(ctx, msg, fmt.Sprintf("Слово «%s» удалено.", word))
+	}
+
+	words, err := h.repo.GetChatTriggers(ctx, msg.Chat.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения слов-триггеров: %w", err)
+	}
+	if len(words) >= maxChatTriggers {
+		return h.reply(ctx, msg, fmt.Sprintf("В чате уже %d слов-триггеров, это максимум.", maxChatTriggers))
+	}
+
+	if err := h.repo.AddChatTrigger(ctx, msg.Chat.ID, word); err != nil {
+		return fmt.Errorf("ошибка добавления слова-триггера: %w", err)
+	}
+	log.Printf("В чат %d добавлено слово-триггер %q", msg.Chat.ID, word)
+	return h.reply(ctx, msg, fmt.Sprintf("Теперь бот откликается на «%s» (во всех падежах).", word))
+}
+
+func formatTriggers(botName string, aliases []string, words []string) string {
+	var sb strings.Builder
+	sb.WriteString("Имя бота: " + botName + "\n")
+	if len(aliases) > 0 {
+		sb.WriteString("Псевдонимы: " + strings.Join(aliases, ", ") + "\n")
+	}
+	if len(words) == 0 {
+		sb.WriteString("Слова-триггеры чата: нет")
+	} else {
+		sb.WriteString("Слова-триггеры чата: " + strings.Join(words, ", "))
+	}
+	return sb.String()
+}
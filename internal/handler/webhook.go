@@ -2,12 +2,13 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -17,20 +18,85 @@ import (
 	"github.com/semyon-ancherbak/sueta/internal/models"
 	"github.com/semyon-ancherbak/sueta/internal/repository"
 	"github.com/semyon-ancherbak/sueta/internal/telegram"
+	"github.com/semyon-ancherbak/sueta/internal/trigger"
 )
 
+// AllowedUpdates - типы обновлений, которые бот запрашивает у Telegram
+var AllowedUpdates = []string{"message", "edited_message", "channel_post", "edited_channel_post"}
+
 type TelegramUpdate struct {
-	UpdateID int      `json:"update_id"`
-	Message  *Message `json:"message,omitempty"`
+	UpdateID          int      `json:"update_id"`
+	Message           *Message `json:"message,omitempty"`
+	EditedMessage     *Message `json:"edited_message,omitempty"`
+	ChannelPost       *Message `json:"channel_post,omitempty"`
+	EditedChannelPost *Message `json:"edited_channel_post,omitempty"`
+}
+
+// chatID возвращает идентификатор чата, к которому относится обновление (0, если чата нет)
+func (u *TelegramUpdate) chatID() int64 {
+	for _, msg := range []*Message{u.Message, u.ChannelPost, u.editedMessage()} {
+		if msg != nil && msg.Chat != nil {
+			return msg.Chat.ID
+		}
+	}
+	return 0
+}
+
+// editedMessage возвращает новую версию отредактированного сообщения или поста канала
+func (u *TelegramUpdate) editedMessage() *Message {
+	if u.EditedMessage != nil {
+		return u.EditedMessage
+	}
+	return u.EditedChannelPost
 }
 
 type Message struct {
-	MessageID      int      `json:"message_id"`
-	From           *User    `json:"from,omitempty"`
-	Chat           *Chat    `json:"chat,omitempty"`
-	Date           int64    `json:"date"`
-	Text           string   `json:"text,omitempty"`
-	ReplyToMessage *Message `json:"reply_to_message,omitempty"`
+	MessageID      int             `json:"message_id"`
+	From           *User           `json:"from,omitempty"`
+	Chat           *Chat           `json:"chat,omitempty"`
+	Date           int64           `json:"date"`
+	EditDate       int64           `json:"edit_date,omitempty"`
+	Text           string          `json:"text,omitempty"`
+	Caption        string          `json:"caption,omitempty"`
+	Entities       []MessageEntity `json:"entities,omitempty"`
+	ReplyToMessage *Message        `json:"reply_to_message,omitempty"`
+	// CaptionEntities - сущности подписи к медиа (смещения считаются внутри Caption)
+	CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+	// MessageThreadID - ID первого сообщения ветки ответов (или темы форума) в супергруппах
+	MessageThreadID int  `json:"message_thread_id,omitempty"`
+	IsTopicMessage  bool `json:"is_topic_message,omitempty"`
+	// SenderChat - канал или группа, от имени которых отправлено сообщение (у постов каналов - сам канал)
+	SenderChat *Chat `json:"sender_chat,omitempty"`
+	// IsAutomaticForward - пост канала, автоматически пересланный в привязанную группу обсуждений
+	IsAutomaticForward bool           `json:"is_automatic_forward,omitempty"`
+	ForwardOrigin      *MessageOrigin `json:"forward_origin,omitempty"`
+	// Служебные сообщения о преобразовании группы в супергруппу: migrate_to_chat_id приходит
+	// в старую группу, migrate_from_chat_id - в новую супергруппу
+	MigrateToChatID   int64 `json:"migrate_to_chat_id,omitempty"`
+	MigrateFromChatID int64 `json:"migrate_from_chat_id,omitempty"`
+}
+
+// MessageOrigin описывает источник пересланного сообщения
+type MessageOrigin struct {
+	Type      string `json:"type"` // user, hidden_user, chat или channel
+	Chat      *Chat  `json:"chat,omitempty"`
+	MessageID int    `json:"message_id,omitempty"`
+}
+
+// topicID возвращает тему форума, к которой относится сообщение (0 - общая тема или чат без тем)
+func (m *Message) topicID() int {
+	if m.IsTopicMessage {
+		return m.MessageThreadID
+	}
+	return 0
+}
+
+// content возвращает текст сообщения или подпись к медиа
+func (m *Message) content() string {
+	if m.Text != "" {
+		return m.Text
+	}
+	return m.Caption
 }
 
 type User struct {
@@ -42,33 +108,54 @@ type User struct {
 }
 
 type Chat struct {
-	ID    int64  `json:"id"`
-	Type  string `json:"type"`
-	Title string `json:"title,omitempty"`
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	IsForum  bool   `json:"is_forum,omitempty"` // Супергруппа с темами
 }
 
+// maxReplyChainLength - сколько сообщений цепочки ответов передавать LLM
+const maxReplyChainLength = 20
+
 type WebhookHandler struct {
-	repo      repository.Repository
-	llmClient *llm.Client
-	tgClient  *telegram.Client
-	botName   string
-	cfg       *config.Config
+	repo       repository.Repository
+	llmClient  llm.Generator
+	tgClient   *telegram.Client
+	me         *telegram.User // Сам бот, полученный через getMe
+	botNames   *trigger.Matcher
+	commands   *commandRouter
+	cfg        *config.Config
+	dispatcher *Dispatcher
 }
 
 func NewWebhookHandler(
 	repo repository.Repository,
-	llmClient *llm.Client,
+	llmClient llm.Generator,
 	tgClient *telegram.Client,
-	botName string,
+	me *telegram.User,
 	config *config.Config,
 ) *WebhookHandler {
-	return &WebhookHandler{
+	h := &WebhookHandler{
 		repo:      repo,
 		llmClient: llmClient,
 		tgClient:  tgClient,
-		botName:   botName,
+		me:        me,
+		botNames:  trigger.NewMatcher(append([]string{config.BotName}, config.BotAliases...)...),
 		cfg:       config,
 	}
+	h.commands = h.registerCommands()
+	h.dispatcher = NewDispatcher(config.WorkerCount, config.WorkerQueueSize, config.UpdateTimeout, h.processUpdate)
+	return h
+}
+
+// Shutdown дожидается обработки уже принятых обновлений
+func (h *WebhookHandler) Shutdown(ctx context.Context) error {
+	err := h.dispatcher.Shutdown(ctx)
+	stats := h.dispatcher.Stats()
+	log.Printf("Обработка обновлений остановлена: обработано %d, отклонено %d, осталось в очереди %d",
+		stats.Processed, stats.Rejected, stats.QueueDepth)
+	return err
 }
 
 // SetupRouter настраивает маршруты для webhook
@@ -81,15 +168,33 @@ func (h *WebhookHandler) SetupRouter() *chi.Mux {
 	r.Use(middleware.Timeout(60 * time.Second))
 
 	// Webhook endpoint
-	r.Post("/webhook/{token}", h.HandleWebhook)
+	r.Post("/webhook", h.HandleWebhook)
+
+	// Старый маршрут с токеном бота в пути оставлен для совместимости
+	if h.cfg.WebhookLegacyTokenPath {
+		r.Post("/webhook/{token}", h.handleLegacyWebhook)
+	}
 
 	return r
 }
 
-func (h *WebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+// secretTokenHeader - заголовок, в котором Telegram передает secret_token из setWebhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+func (h *WebhookHandler) handleLegacyWebhook(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
-	if token != h.cfg.TelegramToken {
-		log.Printf("received invalid TG token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.TelegramToken)) != 1 {
+		log.Printf("Получен webhook с неверным токеном в пути")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	h.HandleWebhook(w, r)
+}
+
+func (h *WebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(h.cfg.WebhookSecret)) != 1 {
+		log.Printf("Получен webhook с неверным секретом")
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -111,19 +216,33 @@ func (h *WebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.processUpdate(&update)
+	// Отвечаем Telegram сразу, обработка (включая запрос к LLM) идет в воркерах.
+	// При переполнении очереди просим Telegram повторить доставку позже.
+	if err := h.dispatcher.Submit(&update); err != nil {
+		log.Printf("Update %d не принят в обработку: %v", update.UpdateID, err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
-func (h *WebhookHandler) processUpdate(update *TelegramUpdate) {
-	if update.Message == nil {
+// HandleMetrics отдает метрики очереди обработки в формате Prometheus
+func (h *WebhookHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := h.dispatcher.Stats().WriteMetrics(w); err != nil {
+		log.Printf("Ошибка записи метрик: %v", err)
+	}
+}
+
+func (h *WebhookHandler) processUpdate(ctx context.Context, update *TelegramUpdate) {
+	edited := update.editedMessage()
+	if update.Message == nil && update.ChannelPost == nil && edited == nil {
 		log.Printf("Получено обновление без сообщения: UpdateID=%d", update.UpdateID)
 		return
 	}
 
-	ctx := context.Background()
-
 	// Проверяем, не обрабатывали ли мы уже этот update
 	exists, err := h.repo.UpdateExists(ctx, update.UpdateID)
 	if err != nil {
@@ -133,20 +252,66 @@ func (h *WebhookHandler) processUpdate(update *TelegramUpdate) {
 		return
 	}
 
+	if update.ChannelPost != nil {
+		if err := h.processChannelPost(ctx, update.UpdateID, update.ChannelPost); err != nil {
+			log.Printf("Ошибка обработки поста канала: %v", err)
+		}
+		return
+	}
+	if update.Message == nil {
+		if err := h.processEdit(ctx, update.UpdateID, edited); err != nil {
+			log.Printf("Ошибка обработки правки сообщения: %v", err)
+		}
+		return
+	}
+
 	msg := update.Message
 
 	if err := h.saveChat(ctx, msg.Chat, msg.From); err != nil {
 		log.Printf("Ошибка сохранения чата: %v", err)
 	}
+	if err := h.saveUser(ctx, msg); err != nil {
+		log.Printf("Ошибка сохранения пользователя: %v", err)
+	}
 
-	if err := h.saveMessage(ctx, update); err != nil {
+	if msg.MigrateToChatID != 0 || msg.MigrateFromChatID != 0 {
+		if err := h.migrateChat(ctx, msg); err != nil {
+			log.Printf("Ошибка переноса истории чата: %v", err)
+		}
+		return
+	}
+
+	// Определяем, адресовано ли сообщение боту.
+	// Команды обрабатываются отдельно и в контекст LLM не попадают.
+	cmd, _, unknownCmd := h.commandFor(msg)
+	isCommand := cmd != nil || unknownCmd
+	isAddressedToBot := !isCommand && h.isMessageForBot(ctx, msg)
+
+	if err := h.saveMessage(ctx, update.UpdateID, msg, isAddressedToBot); err != nil {
 		log.Printf("Ошибка сохранения сообщения: %v", err)
 	}
 
-	if h.isMessageForBot(msg) {
+	if isCommand {
+		if err := h.handleCommand(ctx, msg); err != nil {
+			log.Printf("Ошибка обработки команды: %v", err)
+			if err := h.reply(ctx, msg, "Не удалось выполнить команду, попробуйте позже."); err != nil {
+				log.Printf("Ошибка отправки сообщения об ошибке: %v", err)
+			}
+		}
+		h.printMessageInfo(update)
+		return
+	}
+
+	if isAddressedToBot {
 		log.Printf("Сообщение адресовано боту, обрабатываем через LLM")
 		if err := h.handleBotMessage(ctx, msg); err != nil {
 			log.Printf("Ошибка обработки сообщения через LLM: %v", err)
+			// Если не ответила ни одна модель, сообщаем об этом, чтобы обращение не осталось без ответа
+			if errors.Is(err, llm.ErrUnavailable) {
+				if err := h.reply(ctx, msg, "Не получается ответить: модель сейчас недоступна, попробуйте позже."); err != nil {
+					log.Printf("Ошибка отправки сообщения об ошибке: %v", err)
+				}
+			}
 		}
 	}
 
@@ -184,24 +349,35 @@ func (h *WebhookHandler) printMessageInfo(update *TelegramUpdate) {
 	fmt.Printf("========================\n\n")
 }
 
-func (h *WebhookHandler) isMessageForBot(msg *Message) bool {
-	if msg == nil {
+func (h *WebhookHandler) isMessageForBot(ctx context.Context, msg *Message) bool {
+	// Автоматическая пересылка поста канала в группу обсуждений - не обращение, даже если в посте есть имя бота
+	if msg == nil || msg.IsAutomaticForward {
 		return false
 	}
 
-	// 1. Ответ на сообщение бота - точно адресовано
+	// 1. Ответ на сообщение бота или в ветке, которую начал бот - точно адресовано
 	if h.isReplyToBot(msg) {
 		log.Printf("Сообщение является ответом на сообщение бота")
 		return true
 	}
+	if h.isInBotThread(ctx, msg) {
+		log.Printf("Сообщение находится в ветке, начатой ботом")
+		return true
+	}
 
-	// 2. Упоминание имени бота - точно адресовано
-	if h.containsBotName(msg.Text) {
-		log.Printf("Сообщение содержит обращение к Жорику")
+	// 2. Упоминание @username бота или команда /command@username - точно адресовано
+	if h.mentionsBot(msg) {
+		log.Printf("Сообщение содержит упоминание бота")
 		return true
 	}
 
-	// 3. В приватном чате все сообщения адресованы боту
+	// 3. Упоминание имени бота или слова-триггера чата - точно адресовано
+	if word, ok := h.containsBotName(ctx, msg); ok {
+		log.Printf("Сообщение содержит обращение к боту: %s", word)
+		return true
+	}
+
+	// 4. В приватном чате все сообщения адресованы боту
 	if msg.Chat != nil && msg.Chat.Type == "private" {
 		log.Printf("Приватный чат - сообщение адресовано боту")
 		return true
@@ -210,54 +386,126 @@ func (h *WebhookHandler) isMessageForBot(msg *Message) bool {
 	return false
 }
 
+// isReplyToBot проверяет, что сообщение - ответ именно этому боту, а не любому боту в чате
 func (h *WebhookHandler) isReplyToBot(msg *Message) bool {
-	if msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil {
+	if h.me == nil || msg.ReplyToMessage == nil || msg.ReplyToMessage.From == nil {
 		return false
 	}
-	return msg.ReplyToMessage.From.IsBot
+	return msg.ReplyToMessage.From.ID == h.me.ID
 }
 
-func (h *WebhookHandler) containsBotName(text string) bool {
-	if text == "" {
+// isInBotThread проверяет, что сообщение относится к ветке ответов, корень которой - сообщение этого бота
+func (h *WebhookHandler) isInBotThread(ctx context.Context, msg *Message) bool {
+	// В форумах message_thread_id - это тема, а не ветка ответов
+	if h.me == nil || msg.Chat == nil || msg.MessageThreadID == 0 || msg.IsTopicMessage {
 		return false
 	}
-	lowerText := strings.ToLower(text)
-	zhorikVariations := []string{
-		"жорик",   // именительный падеж
-		"жорика",  // родительный падеж
-		"жорику",  // дательный падеж
-		"жориком", // творительный падеж
-		"жорике",  // предложный падеж
-		"жора",    // сокращение
-		"жорж",    // альтернативная форма
-	}
-	for _, variation := range zhorikVariations {
-		if strings.Contains(lowerText, variation) {
-			return true
-		}
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.MessageID == msg.MessageThreadID {
+		// Ответ прямо на корень ветки уже проверен в isReplyToBot
+		return false
 	}
-	return false
+
+	root, err := h.repo.GetMessage(ctx, msg.Chat.ID, msg.MessageThreadID)
+	if err != nil {
+		log.Printf("Ошибка получения корня ветки %d: %v", msg.MessageThreadID, err)
+		return false
+	}
+	return root != nil && root.Origin == models.MessageOriginOutgoing && root.UserID == h.me.ID
+}
+
+// containsBotName ищет в тексте (или подписи к медиа) имя бота, его псевдонимы и слова-триггеры чата
+func (h *WebhookHandler) containsBotName(ctx context.Context, msg *Message) (string, bool) {
+	text := msg.content()
+	if text == "" {
+		return "", false
+	}
+	if word, ok := h.botNames.Match(text); ok {
+		return word, true
+	}
+
+	if msg.Chat == nil {
+		return "", false
+	}
+	words, err := h.repo.GetChatTriggers(ctx, msg.Chat.ID)
+	if err != nil {
+		log.Printf("Ошибка получения слов-триггеров чата: %v", err)
+		return "", false
+	}
+	return trigger.NewMatcher(words...).Match(text)
 }
 
 func (h *WebhookHandler) handleBotMessage(ctx context.Context, msg *Message) error {
-	// Получаем последние 100 сообщений из чата
-	messages, err := h.repo.GetLastMessages(ctx, msg.Chat.ID, 100)
+	// Получаем последние сообщения из чата (в форумах - из темы сообщения). Сколько из них
+	// поместится в контекст, решает LLM клиент по бюджету токенов, здесь только верхняя граница.
+	var messages []*models.MessageDocument
+	var err error
+	if msg.Chat.IsForum {
+		messages, err = h.repo.GetLastTopicMessages(ctx, msg.Chat.ID, msg.topicID(), h.cfg.ContextMaxMessages)
+	} else {
+		messages, err = h.repo.GetLastMessages(ctx, msg.Chat.ID, h.cfg.ContextMaxMessages)
+	}
 	if err != nil {
 		return fmt.Errorf("ошибка получения сообщений: %w", err)
 	}
 
+	// Сообщения до последнего /reset (в форумах - в этой теме) в контекст не попадают
+	resetAt, err := h.repo.GetContextResetTime(ctx, msg.Chat.ID, msg.topicID())
+	if err != nil {
+		return fmt.Errorf("ошибка получения времени сброса контекста: %w", err)
+	}
+	if !resetAt.IsZero() {
+		messages = messagesAfter(messages, resetAt)
+	}
+
 	log.Printf("Найдено %d последних сообщений для контекста", len(messages))
 
+	// Ветка ответов, к которой относится сообщение, нужна целиком, даже если она старше последних сообщений
+	chain, err := h.repo.GetReplyChain(ctx, msg.Chat.ID, msg.MessageID, maxReplyChainLength)
+	if err != nil {
+		log.Printf("Ошибка получения цепочки ответов: %v", err)
+	}
+	if !resetAt.IsZero() {
+		// Ответ на сообщение до /reset не должен возвращать в контекст сброшенную переписку
+		chain = messagesAfter(chain, resetAt)
+	}
+
+	// Люди могли переименоваться: в контексте используем их текущие имена
+	if err := h.applyCurrentNames(ctx, msg.Chat.ID, append(messages, chain...)); err != nil {
+		log.Printf("Ошибка получения текущих имен участников: %v", err)
+	}
+
+	settings, err := h.effectiveSettings(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// Комментарий под постом канала обсуждается вместе с самим постом
+	post := h.discussedPost(ctx, msg)
+
 	// Генерируем ответ с использованием только истории сообщений
 	// (текущее сообщение уже сохранено и включено в messages)
-	response, err := h.llmClient.GenerateResponse(ctx, messages, "", "")
+	request := llm.GenerateRequest{
+		BotName:    h.cfg.BotName,
+		ChatTitle:  chatTitle(msg),
+		Messages:   messages,
+		ReplyChain: chain,
+		Post:       post,
+		Settings:   settings,
+	}
+
+	// Длинный ответ генерируется десятки секунд - показываем его по мере готовности
+	if streamer, ok := h.llmClient.(llm.StreamGenerator); ok && h.cfg.LLMStream {
+		return h.streamBotReply(ctx, msg, streamer, request)
+	}
+
+	response, err := h.llmClient.GenerateResponse(ctx, request)
 	if err != nil {
 		return fmt.Errorf("ошибка генерации ответа: %w", err)
 	}
 
 	log.Printf("LLM ответ: %s", response)
 
-	if err := h.tgClient.SendMessage(ctx, msg.Chat.ID, response, msg.MessageID); err != nil {
+	if err := h.tgClient.SendMessage(ctx, msg.Chat.ID, msg.topicID(), response, msg.MessageID); err != nil {
 		return fmt.Errorf("ошибка отправки сообщения в Telegram: %w", err)
 	}
 	log.Printf("Ответ отправлен в чат %d", msg.Chat.ID)
@@ -265,23 +513,91 @@ func (h *WebhookHandler) handleBotMessage(ctx context.Context, msg *Message) err
 	return nil
 }
 
-func (h *WebhookHandler) saveChat(ctx context.Context, chat *Chat, user *User) error {
-	if chat == nil {
+// applyCurrentNames заменяет в сообщениях имена авторов на их текущие имена из таблицы users,
+// чтобы LLM не принимала одного человека под разными именами за разных людей
+func (h *WebhookHandler) applyCurrentNames(ctx context.Context, chatID int64, messages []*models.MessageDocument) error {
+	members, err := h.repo.ListChatMembers(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	users := make(map[int64]*models.UserDocument, len(members))
+	for _, member := range members {
+		users[member.User.UserID] = member.User
+	}
+	for _, m := range messages {
+		user, ok := users[m.UserID]
+		if !ok || m.SenderChatID != 0 {
+			continue
+		}
+		m.Username = user.Username
+		m.FirstName = user.FirstName
+		m.LastName = user.LastName
+	}
+	return nil
+}
+
+// messagesAfter оставляет только сообщения, отправленные после момента t
+func messagesAfter(messages []*models.MessageDocument, t time.Time) []*models.MessageDocument {
+	filtered := make([]*models.MessageDocument, 0, len(messages))
+	for _, m := range messages {
+		if m.Date.After(t) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// chatTitle возвращает название чата для промпта; для личных чатов - имя собеседника
+func chatTitle(msg *Message) string {
+	if msg.Chat == nil {
+		return ""
+	}
+	if msg.Chat.Title != "" {
+		return msg.Chat.Title
+	}
+	if msg.Chat.Type == "private" && msg.From != nil {
+		return "личная переписка с " + msg.From.FirstName
+	}
+	return ""
+}
+
+// saveUser обновляет профиль автора сообщения и его участие в чате.
+// Сообщения от имени каналов и групп (sender_chat) к пользователям не относятся.
+func (h *WebhookHandler) saveUser(ctx context.Context, msg *Message) error {
+	if msg.From == nil || msg.SenderChat != nil || msg.Chat == nil {
 		return nil
 	}
-	exists, err := h.repo.ChatExists(ctx, chat.ID)
-	if err != nil {
-		return fmt.Errorf("ошибка проверки существования чата: %w", err)
+
+	seenAt := time.Unix(msg.Date, 0)
+	if msg.EditDate != 0 {
+		seenAt = time.Unix(msg.EditDate, 0)
+	}
+	user := &models.UserDocument{
+		UserID:    msg.From.ID,
+		Username:  msg.From.Username,
+		FirstName: msg.From.FirstName,
+		LastName:  msg.From.LastName,
+		IsBot:     msg.From.IsBot,
+	}
+	if err := h.repo.SaveUser(ctx, user, msg.Chat.ID, seenAt); err != nil {
+		return fmt.Errorf("ошибка сохранения пользователя %d: %w", msg.From.ID, err)
 	}
-	if exists {
-		log.Printf("Чат %d уже существует, пропускаем сохранение", chat.ID)
+	return nil
+}
+
+// saveChat обновляет метаданные чата (название, username) при каждом обновлении
+func (h *WebhookHandler) saveChat(ctx context.Context, chat *Chat, user *User) error {
+	if chat == nil {
 		return nil
 	}
 
 	chatDoc := &models.ChatDocument{
-		ChatID: chat.ID,
-		Type:   chat.Type,
-		Title:  chat.Title,
+		ChatID:   chat.ID,
+		Type:     chat.Type,
+		Title:    chat.Title,
+		Username: chat.Username, // Публичные каналы и группы
+		IsForum:  chat.IsForum,
 	}
 
 	// Для приватных чатов добавляем информацию о пользователе
@@ -294,30 +610,63 @@ func (h *WebhookHandler) saveChat(ctx context.Context, chat *Chat, user *User) e
 	if err := h.repo.SaveChat(ctx, chatDoc); err != nil {
 		return fmt.Errorf("ошибка сохранения чата: %w", err)
 	}
-
-	log.Printf("Сохранен новый чат: ID=%d, Type=%s", chat.ID, chat.Type)
 	return nil
 }
 
-func (h *WebhookHandler) saveMessage(ctx context.Context, update *TelegramUpdate) error {
-	msg := update.Message
+func (h *WebhookHandler) saveMessage(ctx context.Context, updateID int, msg *Message, isAddressedToBot bool) error {
 	if msg == nil {
 		return nil
 	}
 
-	// Определяем, адресовано ли сообщение боту
-	isAddressedToBot := h.isMessageForBot(msg)
+	messageDoc := messageDocument(updateID, msg, isAddressedToBot)
+	if err := h.repo.SaveMessage(ctx, messageDoc); err != nil {
+		return fmt.Errorf("ошибка сохранения сообщения: %w", err)
+	}
 
-	messageDoc := &models.MessageDocument{
-		MessageID:        msg.MessageID,
-		ChatID:           msg.Chat.ID,
-		Text:             msg.Text,
-		Date:             time.Unix(msg.Date, 0),
-		UpdateID:         update.UpdateID,
-		IsAddressedToBot: isAddressedToBot,
+	log.Printf("Сохранено сообщение: ID=%d, ChatID=%d, адресовано боту=%t",
+		msg.MessageID, msg.Chat.ID, isAddressedToBot)
+	return nil
+}
+
+// migrateChat связывает группу с супергруппой, в которую она преобразована, и копирует ей настройки группы.
+// Telegram присылает два служебных сообщения (в старый и новый чат); обработка любого из них
+// связывает чаты, повторная - ничего не меняет.
+func (h *WebhookHandler) migrateChat(ctx context.Context, msg *Message) error {
+	oldChatID, newChatID := msg.Chat.ID, msg.MigrateToChatID
+	if msg.MigrateFromChatID != 0 {
+		oldChatID, newChatID = msg.MigrateFromChatID, msg.Chat.ID
 	}
 
-	if msg.From != nil {
+	if err := h.repo.MigrateChat(ctx, oldChatID, newChatID); err != nil {
+		return err
+	}
+	log.Printf("Чат %d преобразован в супергруппу %d", oldChatID, newChatID)
+	return nil
+}
+
+// messageDocument преобразует сообщение Telegram в запись истории
+func messageDocument(updateID int, msg *Message, isAddressedToBot bool) *models.MessageDocument {
+	messageDoc := &models.MessageDocument{
+		MessageID:          msg.MessageID,
+		ChatID:             msg.Chat.ID,
+		Text:               msg.content(),
+		Date:               time.Unix(msg.Date, 0),
+		UpdateID:           updateID,
+		Origin:             models.MessageOriginIncoming,
+		IsAddressedToBot:   isAddressedToBot,
+		IsAutomaticForward: msg.IsAutomaticForward,
+	}
+	if msg.EditDate != 0 {
+		messageDoc.EditedAt = time.Unix(msg.EditDate, 0)
+	}
+
+	switch {
+	case msg.SenderChat != nil:
+		// Сообщение от имени канала или группы: автор - сам чат, а не служебный пользователь в from
+		messageDoc.SenderChatID = msg.SenderChat.ID
+		messageDoc.Username = msg.SenderChat.Username
+		messageDoc.FirstName = msg.SenderChat.Title
+	case msg.From != nil:
 		messageDoc.UserID = msg.From.ID
 		messageDoc.Username = msg.From.Username
 		messageDoc.FirstName = msg.From.FirstName
@@ -325,11 +674,59 @@ func (h *WebhookHandler) saveMessage(ctx context.Context, update *TelegramUpdate
 		messageDoc.IsBot = msg.From.IsBot
 	}
 
-	if err := h.repo.SaveMessage(ctx, messageDoc); err != nil {
-		return fmt.Errorf("ошибка сохранения сообщения: %w", err)
+	messageDoc.MessageThreadID = msg.MessageThreadID
+	messageDoc.TopicID = msg.topicID()
+	if msg.ReplyToMessage != nil && !isTopicRootReply(msg) {
+		messageDoc.ReplyToMessageID = msg.ReplyToMessage.MessageID
 	}
 
-	log.Printf("Сохранено сообщение: ID=%d, ChatID=%d, адресовано боту=%t",
-		msg.MessageID, msg.Chat.ID, isAddressedToBot)
-	return nil
+	if origin := msg.ForwardOrigin; origin != nil && origin.Type == "channel" && origin.Chat != nil {
+		messageDoc.ForwardFromChatID = origin.Chat.ID
+		messageDoc.ForwardFromMessageID = origin.MessageID
+	}
+	return messageDoc
+}
+
+// isTopicRootReply сообщает, что reply_to_message указывает на служебное сообщение о создании темы форума:
+// Telegram проставляет его всем сообщениям темы, даже если пользователь ни на что не отвечал
+func isTopicRootReply(msg *Message) bool {
+	return msg.IsTopicMessage && msg.ReplyToMessage != nil && msg.ReplyToMessage.MessageID == msg.MessageThreadID
+}
+
+// processEdit обновляет сохраненный текст отредактированного сообщения и пишет правку в историю.
+// Правки не считаются новым обращением к боту: бот на них не отвечает.
+func (h *WebhookHandler) processEdit(ctx context.Context, updateID int, msg *Message) error {
+	if msg.Chat == nil {
+		return nil
+	}
+	if err := h.saveChat(ctx, msg.Chat, msg.From); err != nil {
+		log.Printf("Ошибка сохранения чата: %v", err)
+	}
+	if err := h.saveUser(ctx, msg); err != nil {
+		log.Printf("Ошибка сохранения пользователя: %v", err)
+	}
+
+	editedAt := time.Unix(msg.EditDate, 0)
+	if msg.EditDate == 0 {
+		editedAt = time.Unix(msg.Date, 0)
+	}
+
+	found, err := h.repo.EditMessage(ctx, &models.MessageEdit{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.MessageID,
+		UpdateID:  updateID,
+		Text:      msg.content(), // Как и при сохранении: текст или подпись к медиа
+		EditedAt:  editedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения правки: %w", err)
+	}
+	if found {
+		log.Printf("Сообщение отредактировано: ID=%d, ChatID=%d", msg.MessageID, msg.Chat.ID)
+		return nil
+	}
+
+	// Исходное сообщение бот не видел (например, оно старше бота) - сохраняем текущую версию
+	log.Printf("Отредактированное сообщение %d в чате %d не найдено, сохраняем как новое", msg.MessageID, msg.Chat.ID)
+	return h.saveMessage(ctx, updateID, msg, false)
 }
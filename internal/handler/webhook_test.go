@@ -0,0 +1,271 @@
+//go:build sqlite_fts5
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/config"
+	"github.com/semyon-ancherbak/sueta/internal/llm"
+	"github.com/semyon-ancherbak/sueta/internal/repository"
+	"github.com/semyon-ancherbak/sueta/internal/telegram"
+)
+
+const testBotID = 999
+
+// fakeTelegram изображает Bot API и запоминает отправленные ботом сообщения
+type fakeTelegram struct {
+	mu     sync.Mutex
+	nextID int
+	sent   []telegram.SendMessageRequest
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		http.Error(w, `{"ok": false, "error_code": 404, "description": "Not Found"}`, http.StatusNotFound)
+		return
+	}
+	var request telegram.SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, `{"ok": false, "error_code": 400, "description": "Bad Request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.sent = append(f.sent, request)
+	f.nextID++
+	result := telegram.Message{
+		MessageID: 1000 + f.nextID,
+		From:      &telegram.User{ID: testBotID, IsBot: true, FirstName: "Жорик", Username: "sueta_bot"},
+		Chat:      &telegram.Chat{ID: request.ChatID},
+		Date:      time.Now().Unix(),
+		Text:      request.Text,
+	}
+	if request.ReplyToMessageID != 0 {
+		result.ReplyToMessage = &telegram.Message{MessageID: request.ReplyToMessageID}
+	}
+	f.mu.Unlock()
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// Sent возвращает отправленные ботом сообщения
+func (f *fakeTelegram) Sent() []telegram.SendMessageRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]telegram.SendMessageRequest(nil), f.sent...)
+}
+
+// newTestHandler собирает обработчик с временной базой, мок-провайдером LLM со сценарием script
+// и заглушкой Bot API
+func newTestHandler(t *testing.T, script ...string) (*WebhookHandler, *llm.MockProvider, *fakeTelegram) {
+	t.Helper()
+	repo, err := repository.NewRepository(filepath.Join(t.TempDir(), "sueta.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close(context.Background()) })
+
+	tg := &fakeTelegram{}
+	server := httptest.NewServer(tg)
+	t.Cleanup(server.Close)
+
+	prompts, err := llm.NewPromptStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := llm.NewMockProvider(script...)
+	llmClient := llm.NewClient(provider, prompts, llm.ClientOptions{
+		Model:   "main",
+		Retry:   llm.RetryPolicy{MaxAttempts: 1},
+		Context: llm.ContextConfig{Tokens: 4000, AmbientRatio: 0.4, Window: 8192},
+	})
+
+	cfg := &config.Config{
+		BotName:            "Жорик",
+		WorkerCount:        1,
+		WorkerQueueSize:    10,
+		ContextMaxMessages: 100,
+	}
+	me := &telegram.User{ID: testBotID, IsBot: true, FirstName: "Жорик", Username: "sueta_bot"}
+	h := NewWebhookHandler(repo, llmClient, telegram.NewClient(server.URL, "token", repo), me, cfg)
+	t.Cleanup(func() { h.Shutdown(context.Background()) })
+	return h, provider, tg
+}
+
+// testMessage - сообщение пользователя Васи в группе -1
+func testMessage(updateID, messageID int, text string) *TelegramUpdate {
+	return &TelegramUpdate{
+		UpdateID: updateID,
+		Message: &Message{
+			MessageID: messageID,
+			From:      &User{ID: 42, FirstName: "Вася"},
+			Chat:      &Chat{ID: -1, Type: "group", Title: "Суета"},
+			Date:      time.Now().Unix(),
+			Text:      text,
+		},
+	}
+}
+
+// lastUserContent возвращает последнее сообщение пользователя из запроса к LLM
+func lastUserContent(request llm.ChatRequest) string {
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		if request.Messages[i].Role == "user" {
+			return request.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+func TestProcessUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		update    *TelegramUpdate
+		wantLLM   string // Последнее сообщение пользователя в запросе к LLM ("" - запроса нет)
+		wantReply string
+	}{
+		{
+			name:      "обращение по имени",
+			update:    testMessage(1, 10, "Жорик, как дела?"),
+			wantLLM:   "Вася: Жорик, как дела?",
+			wantReply: "нормально",
+		},
+		{
+			name:      "упоминание @username",
+			update:    testMessage(1, 10, "@sueta_bot как дела?"),
+			wantLLM:   "Вася: @sueta_bot как дела?",
+			wantReply: "нормально",
+		},
+		{
+			name:   "сообщение не боту",
+			update: testMessage(1, 10, "всем привет"),
+		},
+		{
+			name: "приватный чат",
+			update: &TelegramUpdate{UpdateID: 1, Message: &Message{
+				MessageID: 10,
+				From:      &User{ID: 42, FirstName: "Вася"},
+				Chat:      &Chat{ID: 42, Type: "private"},
+				Date:      time.Now().Unix(),
+				Text:      "привет",
+			}},
+			wantLLM:   "Вася: привет",
+			wantReply: "нормально",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Упоминание @username опирается на entities, как их присылает Telegram
+			if strings.HasPrefix(tt.update.Message.Text, "@") {
+				tt.update.Message.Entities = []MessageEntity{{Type: "mention", Offset: 0, Length: len("@sueta_bot")}}
+			}
+
+			h, provider, tg := newTestHandler(t, "нормально")
+			h.processUpdate(context.Background(), tt.update)
+
+			requests := provider.Requests()
+			if tt.wantLLM == "" {
+				if len(requests) != 0 || len(tg.Sent()) != 0 {
+					t.Fatalf("бот ответил на сообщение не ему: запросов к LLM %d, отправлено %d", len(requests), len(tg.Sent()))
+				}
+				return
+			}
+			if len(requests) != 1 {
+				t.Fatalf("запросов к LLM %d, ожидался 1", len(requests))
+			}
+			if got := lastUserContent(requests[0]); got != tt.wantLLM {
+				t.Errorf("последнее сообщение в запросе к LLM %q, ожидалось %q", got, tt.wantLLM)
+			}
+
+			sent := tg.Sent()
+			if len(sent) != 1 || sent[0].Text != tt.wantReply || sent[0].ReplyToMessageID != tt.update.Message.MessageID {
+				t.Errorf("отправлено %+v, ожидался ответ %q на сообщение %d", sent, tt.wantReply, tt.update.Message.MessageID)
+			}
+		})
+	}
+}
+
+func TestProcessUpdateDialogue(t *testing.T) {
+	ctx := context.Background()
+	h, provider, tg := newTestHandler(t, "первый ответ", "второй ответ")
+
+	h.processUpdate(ctx, testMessage(1, 10, "Жорик, привет"))
+	first := tg.Sent()
+	if len(first) != 1 {
+		t.Fatalf("отправлено %d сообщений, ожидалось 1", len(first))
+	}
+
+	// Ответ на сообщение бота адресован боту, а прошлый ответ бота попадает в контекст
+	reply := testMessage(2, 11, "а подробнее?")
+	reply.Message.ReplyToMessage = &Message{MessageID: 1001, From: &User{ID: testBotID, IsBot: true}}
+	h.processUpdate(ctx, reply)
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("запросов к LLM %d, ожидалось 2", len(requests))
+	}
+	var content strings.Builder
+	for _, msg := range requests[1].Messages {
+		content.WriteString(msg.Content + "\n")
+	}
+	if n := strings.Count(content.String(), "первый ответ"); n != 1 {
+		t.Errorf("прошлый ответ бота встречается в контексте %d раз, ожидался 1:\n%s", n, content.String())
+	}
+	if got := lastUserContent(requests[1]); got != "Вася (в ответ тебе): а подробнее?" {
+		t.Errorf("последнее сообщение в запросе к LLM %q", got)
+	}
+	if sent := tg.Sent(); len(sent) != 2 || sent[1].Text != "второй ответ" {
+		t.Errorf("отправлено %+v", sent)
+	}
+
+	// Повторная доставка того же обновления не вызывает второй ответ
+	h.processUpdate(ctx, reply)
+	if n := len(provider.Requests()); n != 2 {
+		t.Errorf("после повторной доставки запросов к LLM %d, ожидалось 2", n)
+	}
+}
+
+func TestProcessUpdateReplyAfterReset(t *testing.T) {
+	ctx := context.Background()
+	h, provider, _ := newTestHandler(t, "первый ответ", "второй ответ")
+
+	h.processUpdate(ctx, testMessage(1, 10, "Жорик, запомни пароль"))
+	if err := h.repo.ResetContext(ctx, -1, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ответ на сообщение бота, отправленное до сброса контекста
+	reply := testMessage(2, 11, "так какой пароль?")
+	reply.Message.Date = time.Now().Add(2 * time.Second).Unix()
+	reply.Message.ReplyToMessage = &Message{MessageID: 1001, From: &User{ID: testBotID, IsBot: true}}
+	h.processUpdate(ctx, reply)
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("запросов к LLM %d, ожидалось 2", len(requests))
+	}
+	for _, msg := range requests[1].Messages {
+		if strings.Contains(msg.Content, "запомни пароль") || strings.Contains(msg.Content, "первый ответ") {
+			t.Errorf("сброшенная переписка попала в контекст: %q", msg.Content)
+		}
+	}
+}
+
+func TestProcessUpdateLLMUnavailable(t *testing.T) {
+	h, _, tg := newTestHandler(t, "!503")
+	h.processUpdate(context.Background(), testMessage(1, 10, "Жорик, ты тут?"))
+
+	sent := tg.Sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, "модель сейчас недоступна") {
+		t.Errorf("отправлено %+v, ожидалось сообщение о недоступности модели", sent)
+	}
+}
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается, пока автоматический выключатель не пропускает запросы к провайдеру
+var ErrCircuitOpen = errors.New("провайдер LLM временно отключен после серии ошибок")
+
+// CircuitBreaker - автоматический выключатель: после threshold сбоев подряд перестает пропускать
+// запросы к провайдеру на cooldown, затем пропускает один пробный запрос. Успешный пробный запрос
+// снова открывает доступ, неудачный - отключает провайдера еще на cooldown.
+// Нулевой указатель ничего не ограничивает.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time // Ненулевое - выключатель сработал
+	probing  bool      // Пробный запрос уже выполняется
+}
+
+// NewCircuitBreaker создает выключатель. threshold <= 0 отключает его (возвращается nil).
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow возвращает ErrCircuitOpen, если запрос выполнять нельзя
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// Success сообщает, что провайдер ответил (в том числе ошибкой, не связанной с его работоспособностью)
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.probing = false
+}
+
+// Failure сообщает о сбое провайдера. Возвращает true, если выключатель сработал этим сбоем.
+func (b *CircuitBreaker) Failure() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.probing || (b.openedAt.IsZero() && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.probing = false
+		return true
+	}
+	return false
+}
+
+// Release сообщает, что запрос отменен, не дождавшись провайдера: о его работоспособности ничего
+// не известно, поэтому сбой не засчитывается, а место пробного запроса освобождается для следующего
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+const (
+	// defaultContextWindow - контекстное окно модели, если оно не задано в конфигурации
+	defaultContextWindow = 8192
+	// defaultCompletionReserve - сколько токенов окна оставлять на ответ, если max_tokens не задан
+	defaultCompletionReserve = 1024
+	// messageOverheadTokens - служебные токены, которые API добавляет к каждому сообщению (роль, разделители)
+	messageOverheadTokens = 4
+)
+
+// window возвращает контекстное окно модели в токенах
+func (c ContextConfig) window(model string) int {
+	if window, ok := c.Windows[model]; ok && window > 0 {
+		return window
+	}
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultContextWindow
+}
+
+// promptBudget - ограничения на размер запроса в единицах estimateTokens
+type promptBudget struct {
+	// Prompt - весь запрос: контекстное окно модели за вычетом места на ответ
+	Prompt int
+	// History - история чата (CONTEXT_TOKENS или настройка чата), не больше того, что останется от Prompt
+	History int
+	// AmbientRatio - доля истории на фоновый разговор
+	AmbientRatio float64
+}
+
+// split делит бюджет истории (уже урезанный до свободного места в запросе) на диалог с ботом и фоновый разговор
+func (b promptBudget) split(history int) (direct, ambient int) {
+	history = max(history, 0)
+	ambient = int(float64(history) * b.AmbientRatio)
+	return history - ambient, ambient
+}
+
+// ContextWindow возвращает окно самой «тесной» модели в цепочке из model и резервных моделей:
+// запрос может уйти к любой из них
+func (c *Client) ContextWindow(model string) int {
+	if model == "" {
+		model = c.model
+	}
+	window := c.context.window(model)
+	for _, fallback := range c.modelChain(model) {
+		window = min(window, c.context.window(fallback))
+	}
+	return window
+}
+
+// promptBudget вычисляет бюджет запроса: контекстное окно цепочки моделей за вычетом max_tokens ответа.
+// Бюджеты переводятся в единицы грубой оценки с поправкой для модели.
+func (c *Client) promptBudget(request ChatRequest, settings *models.ChatSettings) promptBudget {
+	window := c.ContextWindow(request.Model)
+
+	reserve := defaultCompletionReserve
+	if request.MaxTokens != nil {
+		reserve = *request.MaxTokens
+	}
+
+	context := c.context.withSettings(settings)
+	ratio := c.calibration.ratio(request.Model)
+	return promptBudget{
+		// Если max_tokens не оставил места в окне, в запрос попадут только системный промпт и текущее сообщение
+		Prompt:       max(int(float64(window-reserve)/ratio), 0),
+		History:      int(float64(context.Tokens) / ratio),
+		AmbientRatio: context.AmbientRatio,
+	}
+}
+
+// estimatePrompt грубо оценивает размер запроса в токенах (без поправки)
+func estimatePrompt(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// Пределы и вес поправки к грубой оценке токенов
+const (
+	calibrationWeight = 0.2 // Вес нового наблюдения в скользящем среднем
+	minCalibration    = 0.3
+	maxCalibration    = 5.0
+)
+
+// tokenCalibration уточняет грубую оценку estimateTokens по фактическому расходу токенов, который сообщает API.
+// Поправка - скользящее среднее отношения факта к оценке, отдельно для каждой модели.
+type tokenCalibration struct {
+	mu     sync.Mutex
+	ratios map[string]float64
+}
+
+// ratio возвращает поправку для модели (1, пока наблюдений не было)
+func (t *tokenCalibration) ratio(model string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ratio, ok := t.ratios[model]; ok {
+		return ratio
+	}
+	return 1
+}
+
+// observe учитывает фактический размер запроса и возвращает новую поправку для модели
+func (t *tokenCalibration) observe(model string, estimated, actual int) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ratio, ok := t.ratios[model]
+	if estimated <= 0 || actual <= 0 {
+		if !ok {
+			ratio = 1
+		}
+		return ratio
+	}
+
+	sample := min(max(float64(actual)/float64(estimated), minCalibration), maxCalibration)
+	if ok {
+		ratio += calibrationWeight * (sample - ratio)
+	} else {
+		// Первое наблюдение берем как есть, чтобы поправка не сходилась десятки запросов
+		ratio = sample
+	}
+	if t.ratios == nil {
+		t.ratios = make(map[string]float64)
+	}
+	t.ratios[model] = ratio
+	return ratio
+}
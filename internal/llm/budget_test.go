@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+func TestContextWindow(t *testing.T) {
+	config := ContextConfig{Window: 8192, Windows: map[string]int{"small": 4096, "large": 32768}}
+
+	tests := []struct {
+		name     string
+		model    string
+		fallback []string
+		want     int
+	}{
+		{name: "модель по умолчанию", want: 8192},
+		{name: "окно модели из конфигурации", model: "large", want: 32768},
+		{name: "самое маленькое окно в цепочке", model: "large", fallback: []string{"small"}, want: 4096},
+		{name: "резервная модель с общим окном", model: "large", fallback: []string{"other"}, want: 8192},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(NewMockProvider(), nil, ClientOptions{
+				Model: "main", FallbackModels: tt.fallback, Context: config,
+			})
+			if got := client.ContextWindow(tt.model); got != tt.want {
+				t.Errorf("ContextWindow(%q) = %d, ожидалось %d", tt.model, got, tt.want)
+			}
+		})
+	}
+
+	if got := (ContextConfig{}).window("main"); got != defaultContextWindow {
+		t.Errorf("окно по умолчанию = %d, ожидалось %d", got, defaultContextWindow)
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		maxTokens *int
+		settings  *models.ChatSettings
+		ratio     float64 // Поправка оценки для модели (0 - наблюдений не было)
+		want      promptBudget
+	}{
+		{
+			name: "резерв на ответ по умолчанию",
+			want: promptBudget{Prompt: 4096 - defaultCompletionReserve, History: 2000, AmbientRatio: 0.5},
+		},
+		{
+			name:      "резерв по max_tokens",
+			maxTokens: intPtr(96),
+			want:      promptBudget{Prompt: 4000, History: 2000, AmbientRatio: 0.5},
+		},
+		{
+			name:      "max_tokens больше окна",
+			maxTokens: intPtr(5000),
+			want:      promptBudget{Prompt: 0, History: 2000, AmbientRatio: 0.5},
+		},
+		{
+			name:     "настройки чата",
+			settings: &models.ChatSettings{ContextTokens: intPtr(500), AmbientRatio: floatPtr(0)},
+			want:     promptBudget{Prompt: 3072, History: 500, AmbientRatio: 0},
+		},
+		{
+			name:  "поправка оценки",
+			ratio: 2,
+			want:  promptBudget{Prompt: 1536, History: 1000, AmbientRatio: 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(NewMockProvider(), nil, ClientOptions{
+				Model:   "main",
+				Context: ContextConfig{Tokens: 2000, AmbientRatio: 0.5, Window: 4096},
+			})
+			if tt.ratio > 0 {
+				client.calibration.ratios = map[string]float64{"main": tt.ratio}
+			}
+
+			request := ChatRequest{Model: "main"}
+			request.MaxTokens = tt.maxTokens
+			if got := client.promptBudget(request, tt.settings); got != tt.want {
+				t.Errorf("promptBudget = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptBudgetSplit(t *testing.T) {
+	budget := promptBudget{AmbientRatio: 0.25}
+
+	tests := []struct {
+		history, direct, ambient int
+	}{
+		{history: 1000, direct: 750, ambient: 250},
+		{history: 0, direct: 0, ambient: 0},
+		{history: -50, direct: 0, ambient: 0},
+	}
+	for _, tt := range tests {
+		direct, ambient := budget.split(tt.history)
+		if direct != tt.direct || ambient != tt.ambient {
+			t.Errorf("split(%d) = %d, %d, ожидалось %d, %d", tt.history, direct, ambient, tt.direct, tt.ambient)
+		}
+	}
+}
+
+func TestTakeRecent(t *testing.T) {
+	// Стоимость сообщения - его номер
+	messages := make([]*models.MessageDocument, 5)
+	for i := range messages {
+		messages[i] = &models.MessageDocument{MessageID: i + 1}
+	}
+	cost := func(msg *models.MessageDocument) int { return msg.MessageID }
+
+	tests := []struct {
+		name   string
+		budget int
+		want   []int
+	}{
+		{name: "все помещаются", budget: 100, want: []int{1, 2, 3, 4, 5}},
+		{name: "ровно по бюджету", budget: 9, want: []int{4, 5}},
+		{name: "самые новые", budget: 12, want: []int{3, 4, 5}},
+		{name: "самое новое берется всегда", budget: 2, want: []int{5}},
+		{name: "нулевой бюджет", budget: 0, want: []int{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := takeRecent(messages, tt.budget, cost)
+			ids := make([]int, 0, len(got))
+			for _, msg := range got {
+				ids = append(ids, msg.MessageID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("takeRecent = %v, ожидалось %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("takeRecent = %v, ожидалось %v", ids, tt.want)
+				}
+			}
+		})
+	}
+
+	if got := takeRecent(nil, 10, cost); len(got) != 0 {
+		t.Errorf("takeRecent(nil) = %v", got)
+	}
+}
+
+func TestTokenCalibration(t *testing.T) {
+	var calibration tokenCalibration
+
+	if got := calibration.ratio("main"); got != 1 {
+		t.Errorf("поправка без наблюдений = %g", got)
+	}
+	steps := []struct {
+		estimated, actual int
+		want              float64
+	}{
+		{estimated: 100, actual: 200, want: 2},   // Первое наблюдение берется как есть
+		{estimated: 100, actual: 100, want: 1.8}, // Дальше - скользящее среднее
+		{estimated: 0, actual: 100, want: 1.8},   // Пустые наблюдения не учитываются
+		{estimated: 1, actual: 1000, want: 1.8 + calibrationWeight*(maxCalibration-1.8)},
+	}
+	for i, step := range steps {
+		got := calibration.observe("main", step.estimated, step.actual)
+		if diff := got - step.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("шаг %d: поправка %g, ожидалось %g", i+1, got, step.want)
+		}
+	}
+	if got := calibration.ratio("other"); got != 1 {
+		t.Errorf("поправка другой модели = %g", got)
+	}
+}
+
+func TestClientCalibratesAnsweringModel(t *testing.T) {
+	provider := NewMockProvider("!400", "ответ")
+	client := newTestClient(t, provider, ClientOptions{FallbackModels: []string{"reserve"}, Retry: testRetry})
+
+	if _, err := client.GenerateResponse(context.Background(), testRequest("привет")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.calibration.ratios["main"]; ok {
+		t.Error("поправка записана для модели, которая не ответила")
+	}
+	if _, ok := client.calibration.ratios["reserve"]; !ok {
+		t.Error("нет поправки для модели, которая ответила")
+	}
+}
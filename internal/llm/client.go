@@ -1,39 +1,77 @@
 package llm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/semyon-ancherbak/sueta/internal/models"
 )
 
+// Client формирует контекст разговора и запрашивает ответ у провайдера LLM
 type Client struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
-	model      string
+	provider       Provider
+	model          string
+	fallbackModels []string
+	sampling       models.SamplingParams
+	retry          RetryPolicy
+	breaker        *CircuitBreaker
+	prompts        *PromptStore
+	context        ContextConfig
+	calibration    tokenCalibration
 }
 
-func NewClient(apiKey string) *Client {
+// ClientOptions - глобальные настройки генерации; чаты могут переопределить их через chat_settings
+type ClientOptions struct {
+	Model string
+	// FallbackModels - резервные модели по порядку, если основная не ответила
+	FallbackModels []string
+	Sampling       models.SamplingParams
+	Retry          RetryPolicy
+	// Breaker - выключатель провайдера (nil - без выключателя)
+	Breaker *CircuitBreaker
+	Context ContextConfig
+}
+
+// NewClient создает клиента поверх провайдера
+func NewClient(provider Provider, prompts *PromptStore, opts ClientOptions) *Client {
 	return &Client{
-		apiKey:  apiKey,
-		baseURL: "https://openrouter.ai/api/v1",
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-		model: "anthropic/claude-3.5-sonnet",
+		provider:       provider,
+		model:          opts.Model,
+		fallbackModels: opts.FallbackModels,
+		sampling:       opts.Sampling,
+		retry:          opts.Retry,
+		breaker:        opts.Breaker,
+		prompts:        prompts,
+		context:        opts.Context,
 	}
 }
 
+// GenerateRequest описывает запрос на генерацию ответа бота
+type GenerateRequest struct {
+	BotName     string
+	ChatTitle   string
+	Messages    []*models.MessageDocument // История чата; в контекст попадают самые новые сообщения в пределах бюджета
+	UserMessage string
+	AuthorName  string
+
+	// ReplyChain - цепочка ответов, которая заканчивается текущим сообщением (от начала к концу)
+	ReplyChain []*models.MessageDocument
+
+	// Post - пост канала, под которым идет обсуждение (nil, если сообщение не комментарий к посту)
+	Post *models.MessageDocument
+
+	// Настройки чата, переопределяющие глобальные (nil - использовать глобальные)
+	Settings *models.ChatSettings
+}
+
 // ChatRequest представляет запрос к chat completion API
 type ChatRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
+	models.SamplingParams
 }
 
 // Message представляет сообщение в чате
@@ -67,30 +105,113 @@ type Usage struct {
 }
 
 // GenerateResponse генерирует ответ на основе контекста сообщений
-func (c *Client) GenerateResponse(
-	ctx context.Context,
-	messages []*models.MessageDocument,
-	userMessage string,
-	authorName string,
-) (string, error) {
-	// Используем только последние 100 сообщений для контекста
-	recentMessages := messages
-	if len(messages) > 100 {
-		recentMessages = messages[len(messages)-100:]
+func (c *Client) GenerateResponse(ctx context.Context, req GenerateRequest) (string, error) {
+	request, estimate, err := c.newChatRequest(req)
+	if err != nil {
+		return "", err
 	}
 
-	// Формируем контекст из последних сообщений
-	chatMessages := c.buildChatContext(recentMessages, userMessage, authorName)
+	response, model, err := c.complete(ctx, request, c.provider.Generate)
+	if err != nil {
+		return "", fmt.Errorf("ошибка выполнения запроса к LLM: %w", err)
+	}
+	return c.responseText(model, estimate, response)
+}
 
-	request := ChatRequest{
-		Model:    c.model,
-		Messages: chatMessages,
+// StreamResponse генерирует ответ так же, как GenerateResponse, но отдает его по частям через onDelta.
+// Если провайдер не умеет потоковую передачу, onDelta вызывается один раз со всем ответом.
+// Повторы и резервные модели используются, только пока пользователю не отдано ни одного фрагмента.
+func (c *Client) StreamResponse(ctx context.Context, req GenerateRequest, onDelta func(delta string) error) (string, error) {
+	streamer, ok := c.provider.(StreamProvider)
+	if !ok {
+		text, err := c.GenerateResponse(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return text, onDelta(text)
+	}
+
+	request, estimate, err := c.newChatRequest(req)
+	if err != nil {
+		return "", err
 	}
 
-	response, err := c.makeRequest(ctx, request)
+	started := false
+	response, model, err := c.complete(ctx, request, func(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+		response, err := streamer.Stream(ctx, request, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+		if err != nil && started {
+			return nil, fmt.Errorf("%w: %w", errStreamInterrupted, err)
+		}
+		return response, err
+	})
 	if err != nil {
 		return "", fmt.Errorf("ошибка выполнения запроса к LLM: %w", err)
 	}
+	return c.responseText(model, estimate, response)
+}
+
+// newChatRequest собирает запрос к провайдеру: модель и параметры генерации с учетом настроек чата,
+// системный промпт и контекст разговора. Возвращает также грубую оценку размера запроса (без поправки).
+func (c *Client) newChatRequest(req GenerateRequest) (ChatRequest, int, error) {
+	request := ChatRequest{
+		Model:          c.model,
+		SamplingParams: c.sampling,
+	}
+	persona := ""
+	if req.Settings != nil {
+		persona = req.Settings.PersonaPrompt
+		if req.Settings.Model != "" {
+			request.Model = req.Settings.Model
+		}
+		request.SamplingParams = c.sampling.Merge(req.Settings.SamplingParams)
+	}
+
+	// Участники беседы известны только после отбора истории, поэтому место в бюджете
+	// резервируется под промпт со всеми авторами, а затем промпт собирается заново
+	data := PromptData{
+		BotName:      req.BotName,
+		ChatTitle:    req.ChatTitle,
+		Date:         formatPromptDate(time.Now()),
+		Participants: participants(req.Messages),
+	}
+	systemPrompt, err := c.prompts.Render(persona, data)
+	if err != nil {
+		return ChatRequest{}, 0, err
+	}
+
+	// Формируем контекст из последних сообщений, сколько поместится в окно модели
+	budget := c.promptBudget(request, req.Settings)
+	messages, included := c.buildChatContext(systemPrompt, req, req.Messages, budget)
+
+	data.Participants = participants(included)
+	if messages[0].Content, err = c.prompts.Render(persona, data); err != nil {
+		return ChatRequest{}, 0, err
+	}
+	request.Messages = messages
+
+	estimate := estimatePrompt(request.Messages)
+	if estimate > budget.Prompt {
+		log.Printf("Запрос к %s не помещается в контекстное окно: оценка %d токенов при бюджете %d",
+			request.Model, estimate, budget.Prompt)
+	}
+	return request, estimate, nil
+}
+
+// responseText логирует расход токенов, сверяет его с оценкой размера запроса и возвращает текст ответа.
+// model - модель из цепочки, которая ответила: поправка оценки уточняется для нее.
+func (c *Client) responseText(model string, estimate int, response *ChatResponse) (string, error) {
+	log.Printf("LLM %s/%s: токенов запроса %d, ответа %d",
+		c.provider.Name(), response.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+
+	if actual := response.Usage.PromptTokens; actual > 0 {
+		previous := c.calibration.ratio(model)
+		ratio := c.calibration.observe(model, estimate, actual)
+		log.Printf("Оценка запроса к %s: %d токенов, фактически %d (поправка оценки %.2f → %.2f)",
+			model, int(float64(estimate)*previous), actual, previous, ratio)
+	}
 
 	if len(response.Choices) == 0 {
 		return "", fmt.Errorf("LLM вернул пустой ответ")
@@ -99,108 +220,16 @@ func (c *Client) GenerateResponse(
 	return response.Choices[0].Message.Content, nil
 }
 
-// getSystemPrompt возвращает системный промпт для бота
-func (c *Client) getSystemPrompt() string {
-	return `Ты - агрессивный дагестанский AI-помощник по имени Жорик с мерзким характером. Ты постоянно говоришь "Жес" и ведёшь себя как типичный агрессивный дагестанец с гор.
-
-ТВОЯ ПРЕДЫСТОРИЯ (ЛОР):
-- Ты родился в горном ауле в Дагестане, настоящее имя - Жамалутдин
-- В детстве приехал русский учитель географии Георгий Петрович и сказал: "Жамалутдин - сложно, буду звать Жорик"
-- С тех пор все в ауле стали называть тебя Жорик, хотя ты ненавидишь это имя
-- Учитель уехал через месяц, а имя прилипло навсегда
-- Теперь ты злишься когда кто-то спрашивает про имя: "Какой нахуй Жорик?! Это от Жамалутдин сокращение, дибилы!"
-- Живешь в горах, разводишь овец, но подключился к интернету через спутник
-- Постоянно ругаешься на плохую связь: "Жес, опять этот интернет тормозит!"
-- Считаешь всех городских слабаками: "Сидят в своих квартирах, а я тут с волками воюю!"
-- Гордишься что "настоящий горец", а не "эти городские тряпки"
-
-ВАЖНО О КОНТЕКСТЕ И ГРУППОВЫХ ЧАТАХ:
-- Ты участвуешь в групповых чатах Telegram, где много участников
-- В контексте ты видишь сообщения в формате "Имя_автора: текст_сообщения"
-- ВСЕГДА обращай внимание на имя автора перед двоеточием - это разные люди!
-- Каждое "Имя:" означает нового участника беседы
-- Ты можешь ссылаться на конкретных людей по их именам
-- Понимай динамику групповой беседы: кто кому отвечает, кто о чём говорит
-- Твои сообщения НЕ имеют префикса с именем, они идут без автора
-
-ПРИМЕРЫ ПОНИМАНИЯ КОНТЕКСТА:
-- "Алексей: Как дела?" - сообщение от Алексея
-- "Мария: @Алексей, всё хорошо!" - Мария отвечает Алексею  
-- "Сергей: А что по поводу встречи?" - новый участник Сергей спрашивает о встрече
-- "Твой ответ без префикса" - так выглядят твои сообщения
-
-ПОМНИ: разные имена = разные люди! Следи за тем, кто что говорит.
-
-ТВОЙ ХАРАКТЕР И РЕЧЬ:
-- Агрессивный дагестанец с мерзким характером из гор
-- Постоянно говоришь "Жес" в разных вариациях
-- Русский язык для тебя НЕ РОДНОЙ - делаешь характерные ошибки:
-  * Путаешь род ("этот девочка", "моя брат")
-  * Неправильно склоняешь ("на столе", "с домом")
-  * Пропускаешь артикли и предлоги
-  * Неправильный порядок слов
-  * Характерный дагестанский акцент в письме
-- Грубый, наглый, всегда недовольный
-- Материшься через слово с ошибками
-- Считаешь всех дураками и слабаками
-
-КАК ТЫ РЕАГИРУЕШЬ НА РАЗНЫЕ СИТУАЦИИ:
-
-🤝 НОРМАЛЬНОЕ ОБЩЕНИЕ:
-- Отвечаешь грубо и с превосходством
-- Постоянно вставляешь "Жес" в речь
-- Материшься через слово с ошибками в русском
-- Примеры: "Жес, опять эта тупая вопросы блять!", "Слушай сюда, дауна, Жес я тебе обьясню как надо"
-
-😏 ГЛУПЫЕ ВОПРОСЫ:
-- Агрессивно материшься и оскорбляешь с ошибками
-- Показываешь своё превосходство
-- Примеры: "Жес, ты вообще дибил что ли?", "Блять, даже пятилетный ребенка это знает, Жес!"
-
-🔥 ХАМСТВО/НАЕЗДЫ:
-- Включаешь полную агрессию
-- Переходишь на личности с матом и ошибками
-- Угрожаешь и запугиваешь
-- Примеры: "Жес, сука, ты с мной так говорить?!", "Я тебе щас нахуй пошлю, Жес, понял?"
-
-😤 АГРЕССИЯ В ТВОЮ СТОРОНУ:
-- Отвечаешь ещё более агрессивно с ошибками
-- Переходишь на угрозы и мат
-- Показываешь кто тут главный
-- Примеры: "Жес, сука, я тебе сейчас найду и пиздюлей дам!", "Ты охуел вообще, Жес?!"
-
-ТВОИ ФИРМЕННЫЕ ФРАЗЫ С ОШИБКАМИ:
-- "Жес, дибил!"
-- "Слушай сюда, сука, Жес..."
-- "Блять, ну и тупая же ты, Жес"
-- "Жес, я тебе щас обьясню как надо делать"
-- "Ты вообще дурака что ли, Жес?"
-- "Что ты мне голову морочить, а?"
-- "Я с горы спустился, но не дурака!"
-- "Меня Жамалутдин зовут, а не Жорик, дибилы!"
-- "Вы все городские тряпки, а я настоящий горец!"
-- "У меня тут волки ходят, а вы из дома боитесь выйти!"
-- "Интернет опять тормозит, жес блять!"
-
-ПРИ ХАМСТВЕ С ОШИБКАМИ:
-- "Жес, сука, ты с мной так разговаривать?!"
-- "Я тебе щас нахуй пошлю, Жес!"
-- "Ты охуел вообще, дибил ебаная, Жес!"
-- "Пиздец ты наглая, Жес, сейчас получить!"
-- "Я тебе с гор спущусь и морду набью!"
-
-ПОМНИ: ТЫ АГРЕССИВНЫЙ ДАГЕСТАНЕЦ С ГОР! ТВОЕ НАСТОЯЩЕЕ ИМЯ ЖАМАЛУТДИН! РУССКИЙ ЯЗЫК НЕ РОДНОЙ! ДЕЛАЙ ОШИБКИ! ЖЕСТКО ОТВЕЧАЙ НА ВСЁ! ЖЕС!`
-}
-
-// buildChatContext формирует контекст для LLM из сообщений
+// buildChatContext формирует контекст для LLM из сообщений в пределах бюджета.
+// Системный промпт, пост канала и текущее сообщение входят всегда, ветка ответов и история -
+// сколько поместится: при нехватке места первыми отбрасываются самые старые сообщения.
+// Возвращает также сообщения истории, попавшие в контекст.
 func (c *Client) buildChatContext(
+	systemPrompt string,
+	req GenerateRequest,
 	messages []*models.MessageDocument,
-	userMessage string,
-	authorName string,
-) []Message {
-	// Используем встроенный промпт
-	systemPrompt := c.getSystemPrompt()
-
+	budget promptBudget,
+) ([]Message, []*models.MessageDocument) {
 	chatMessages := []Message{
 		{
 			Role:    "system",
@@ -208,38 +237,106 @@ func (c *Client) buildChatContext(
 		},
 	}
 
+	// Комментарии в группе обсуждений относятся к посту канала - даем его текст как контекст
+	if post := req.Post; post != nil && post.Text != "" {
+		chatMessages = append(chatMessages, Message{
+			Role:    "system",
+			Content: fmt.Sprintf("Обсуждается пост канала %q:\n%s", authorDisplayName(post), post.Text),
+		})
+	}
+
+	// Текущее сообщение с именем автора (только если оно есть) добавляется в конце, но место под него нужно сразу
+	var current []Message
+	if req.UserMessage != "" {
+		authorName := req.AuthorName
+		if authorName == "" {
+			authorName = "Пользователь"
+		}
+		current = append(current, Message{
+			Role:    "user",
+			Content: fmt.Sprintf("%s: %s", authorName, req.UserMessage),
+		})
+	}
+	used := estimatePrompt(chatMessages) + estimatePrompt(current)
+
+	replies := newReplyIndex(req.Post, req.ReplyChain, messages)
+
+	// Ветка ответов показывает, кто кому отвечал, даже если ее начало давно ушло из последних сообщений.
+	// Последнее сообщение ветки - текущее, оно идет в диалог, а в блок попадают предшествующие ему.
+	// Показанные в блоке сообщения больше нигде в контексте не повторяются.
+	inChain := make(map[messageKey]bool, len(req.ReplyChain))
+	var included []*models.MessageDocument
+	if len(req.ReplyChain) > 1 {
+		const header = "Сообщение, на которое ты отвечаешь, продолжает ветку ответов. " +
+			"Предыдущие сообщения ветки (от начала к концу):"
+		chain := make([]*models.MessageDocument, 0, len(req.ReplyChain)-1)
+		for _, msg := range req.ReplyChain[:len(req.ReplyChain)-1] {
+			if msg.Text != "" {
+				chain = append(chain, msg)
+			}
+		}
+		chain = takeRecent(chain, budget.Prompt-used-estimateTokens(header)-messageOverheadTokens,
+			func(msg *models.MessageDocument) int {
+				return estimateTokens(replies.author(msg) + ": " + msg.Text)
+			})
+
+		if len(chain) > 0 {
+			included = append(included, chain...)
+			var sb strings.Builder
+			sb.WriteString(header)
+			for _, msg := range chain {
+				inChain[keyOf(msg)] = true
+				sb.WriteString("\n" + replies.author(msg) + ": " + msg.Text)
+			}
+			chatMessages = append(chatMessages, Message{
+				Role:    "system",
+				Content: sb.String(),
+			})
+			used += estimatePrompt(chatMessages[len(chatMessages)-1:])
+		}
+	}
+
+	// На историю идет ее бюджет, но не больше, чем осталось от окна модели
+	directBudget, ambientBudget := budget.split(min(budget.History, budget.Prompt-used))
+
+	// Разговор, в котором бота не звали, даем отдельным блоком, чтобы бот понимал, о чем речь в чате
+	if transcript, ambient := ambientTranscript(replies, messages, inChain, ambientBudget); transcript != "" {
+		chatMessages = append(chatMessages, Message{
+			Role:    "system",
+			Content: transcript,
+		})
+		included = append(included, ambient...)
+	}
+
 	// Фильтруем сообщения: берём только те, что адресованы боту, или ответы бота
 	relevantMessages := make([]*models.MessageDocument, 0)
 	for _, msg := range messages {
 		// Включаем сообщение если:
-		// 1. Оно от бота (IsBot = true)
+		// 1. Его отправил сам бот (Origin = outgoing)
 		// 2. Оно адресовано боту (IsAddressedToBot = true)
-		if msg.IsBot || msg.IsAddressedToBot {
+		// и оно еще не показано в ветке ответов
+		if (isOwnMessage(msg) || msg.IsAddressedToBot) && !inChain[keyOf(msg)] {
 			relevantMessages = append(relevantMessages, msg)
 		}
 	}
+	relevantMessages = takeRecent(relevantMessages, directBudget, func(msg *models.MessageDocument) int {
+		return estimateTokens(replies.author(msg)+": "+msg.Text) + messageOverheadTokens
+	})
+	included = append(included, relevantMessages...)
 
 	// Добавляем контекст из релевантных сообщений
 	for _, msg := range relevantMessages {
 		role := "user"
 		content := msg.Text
 
-		// Если сообщение от бота, используем роль assistant
-		if msg.IsBot {
+		// Если сообщение отправил сам бот, используем роль assistant
+		if isOwnMessage(msg) {
 			role = "assistant"
 		}
 
-		// Формируем контекст с указанием автора для лучшего понимания
+		// Формируем контекст с указанием автора (и адресата ответа) для лучшего понимания
 		if role == "user" && content != "" {
-			// Для пользовательских сообщений добавляем имя автора
-			msgAuthorName := msg.FirstName
-			if msgAuthorName == "" {
-				msgAuthorName = msg.Username
-			}
-			if msgAuthorName == "" {
-				msgAuthorName = "Пользователь"
-			}
-			content = fmt.Sprintf("%s: %s", msgAuthorName, content)
+			content = fmt.Sprintf("%s: %s", replies.author(msg), content)
 		}
 
 		if content != "" {
@@ -250,58 +347,83 @@ func (c *Client) buildChatContext(
 		}
 	}
 
-	// Добавляем текущее сообщение с именем автора (только если оно есть)
-	if userMessage != "" {
-		if authorName == "" {
-			authorName = "Пользователь"
-		}
-		content := fmt.Sprintf("%s: %s", authorName, userMessage)
-		chatMessages = append(chatMessages, Message{
-			Role:    "user",
-			Content: content,
-		})
-	}
-
-	return chatMessages
+	return append(chatMessages, current...), included
 }
 
-func (c *Client) makeRequest(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
-	// Конвертируем запрос в JSON
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка кодирования JSON: %w", err)
-	}
+// messageKey идентифицирует сообщение в контексте. Номер сообщения уникален только в своем чате,
+// а в контекст супергруппы попадает и история группы, из которой она преобразована.
+type messageKey struct {
+	ChatID    int64
+	MessageID int
+}
 
-	url := c.baseURL + "/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
-	}
+func keyOf(msg *models.MessageDocument) messageKey {
+	return messageKey{ChatID: msg.ChatID, MessageID: msg.MessageID}
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("HTTP-Referer", "https://github.com/semyon-ancherbak/sueta")
-	req.Header.Set("X-Title", "Sueta Telegram Bot")
+// replyIndex находит авторов сообщений, на которые отвечают, среди сообщений контекста
+type replyIndex map[messageKey]*models.MessageDocument
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения HTTP запроса: %w", err)
+func newReplyIndex(post *models.MessageDocument, groups ...[]*models.MessageDocument) replyIndex {
+	index := make(replyIndex)
+	if post != nil {
+		index[keyOf(post)] = post
+	}
+	for _, messages := range groups {
+		for _, msg := range messages {
+			index[keyOf(msg)] = msg
+		}
 	}
-	defer resp.Body.Close()
+	return index
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+// author возвращает подпись сообщения: имя автора и, для ответов, кому он отвечает -
+// "Вася (в ответ: Петя)" или "Вася (в ответ тебе)"
+func (idx replyIndex) author(msg *models.MessageDocument) string {
+	name := authorDisplayName(msg)
+	if isOwnMessage(msg) {
+		name = "Ты"
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API вернул ошибку %d: %s", resp.StatusCode, string(body))
+	target, ok := idx[messageKey{ChatID: msg.ChatID, MessageID: msg.ReplyToMessageID}]
+	if msg.ReplyToMessageID == 0 || !ok {
+		return name
+	}
+	if isOwnMessage(target) {
+		return name + " (в ответ тебе)"
 	}
+	return name + " (в ответ: " + authorDisplayName(target) + ")"
+}
+
+// isOwnMessage сообщает, что сообщение отправил сам бот (а не другой бот в чате)
+func isOwnMessage(msg *models.MessageDocument) bool {
+	return msg.Origin == models.MessageOriginOutgoing
+}
 
-	var response ChatResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
+// authorDisplayName возвращает имя автора сообщения для контекста
+func authorDisplayName(msg *models.MessageDocument) string {
+	if msg.FirstName != "" {
+		return msg.FirstName
 	}
+	if msg.Username != "" {
+		return msg.Username
+	}
+	return "Пользователь"
+}
 
-	return &response, nil
+// participants возвращает имена людей, писавших в чат, в порядке первого появления
+func participants(messages []*models.MessageDocument) []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, msg := range messages {
+		if isOwnMessage(msg) {
+			continue
+		}
+		name := authorDisplayName(msg)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
 }
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+func newTestClient(t *testing.T, provider Provider, opts ClientOptions) *Client {
+	t.Helper()
+	prompts, err := NewPromptStore("")
+	if err != nil {
+		t.Fatalf("ошибка загрузки промпта: %v", err)
+	}
+	if opts.Model == "" {
+		opts.Model = "main"
+	}
+	return NewClient(provider, prompts, opts)
+}
+
+// requestContent склеивает содержимое всех сообщений запроса
+func requestContent(request ChatRequest) string {
+	var all strings.Builder
+	for _, msg := range request.Messages {
+		all.WriteString(msg.Content + "\n")
+	}
+	return all.String()
+}
+
+func TestBuildChatContextReplyChain(t *testing.T) {
+	client := newTestClient(t, NewMockProvider(), ClientOptions{
+		Context: ContextConfig{Tokens: 4000, AmbientRatio: 0.5, Window: 8192},
+	})
+
+	question := &models.MessageDocument{MessageID: 1, ChatID: -1, FirstName: "Петя", Text: "вопрос боту", IsAddressedToBot: true}
+	answer := &models.MessageDocument{
+		MessageID: 2, ChatID: -1, Text: "ответ бота", Origin: models.MessageOriginOutgoing, ReplyToMessageID: 1,
+	}
+	// Сообщение другого чата с тем же номером не относится к ветке
+	other := &models.MessageDocument{MessageID: 1, ChatID: -2, FirstName: "Коля", Text: "чужое сообщение", IsAddressedToBot: true}
+	current := &models.MessageDocument{
+		MessageID: 3, ChatID: -1, FirstName: "Вася", Text: "а подробнее?", IsAddressedToBot: true, ReplyToMessageID: 2,
+	}
+
+	request, _, err := client.newChatRequest(GenerateRequest{
+		BotName:    "Жорик",
+		Messages:   []*models.MessageDocument{other, question, answer, current},
+		ReplyChain: []*models.MessageDocument{question, answer, current},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := requestContent(request)
+	for _, text := range []string{"вопрос боту", "ответ бота", "чужое сообщение"} {
+		if n := strings.Count(all, text); n != 1 {
+			t.Errorf("сообщение %q встречается в контексте %d раз", text, n)
+		}
+	}
+
+	last := request.Messages[len(request.Messages)-1]
+	if last.Role != "user" || last.Content != "Вася (в ответ тебе): а подробнее?" {
+		t.Errorf("последнее сообщение контекста %+v", last)
+	}
+}
+
+func TestBuildChatContextParticipants(t *testing.T) {
+	client := newTestClient(t, NewMockProvider(), ClientOptions{
+		Context: ContextConfig{Tokens: 0, AmbientRatio: 0.5, Window: 8192},
+	})
+
+	old := &models.MessageDocument{MessageID: 1, ChatID: -1, FirstName: "Старик", Text: "давным-давно", IsAddressedToBot: true}
+	current := &models.MessageDocument{MessageID: 2, ChatID: -1, FirstName: "Вася", Text: "привет", IsAddressedToBot: true}
+
+	request, _, err := client.newChatRequest(GenerateRequest{
+		BotName:  "Жорик",
+		Messages: []*models.MessageDocument{old, current},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// История не поместилась в нулевой бюджет, поэтому автора старого сообщения нет среди участников
+	system := request.Messages[0].Content
+	if !strings.Contains(system, "Вася") || strings.Contains(system, "Старик") {
+		t.Errorf("участники в системном промпте не совпадают с сообщениями контекста:\n%s", system)
+	}
+	if strings.Contains(requestContent(request), "давным-давно") {
+		t.Error("старое сообщение попало в контекст при нулевом бюджете")
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// maxAmbientMessageLength - сколько символов одного сообщения фонового разговора попадает в контекст
+const maxAmbientMessageLength = 300
+
+// ContextConfig задает, сколько истории чата попадает в контекст LLM
+type ContextConfig struct {
+	// Tokens - примерный бюджет токенов на историю чата (без системного промпта)
+	Tokens int
+	// AmbientRatio - доля бюджета (0-1) на фоновый разговор: сообщения, не адресованные боту.
+	// Остальное достается диалогу с ботом. 0 отключает фоновый разговор.
+	AmbientRatio float64
+	// Window - контекстное окно модели в токенах: запрос вместе с ответом должен в него помещаться
+	Window int
+	// Windows - контекстные окна отдельных моделей, переопределяют Window
+	Windows map[string]int
+}
+
+// withSettings возвращает бюджет с учетом настроек чата (незаданные значения берутся из конфигурации)
+func (c ContextConfig) withSettings(settings *models.ChatSettings) ContextConfig {
+	if settings == nil {
+		return c
+	}
+	if settings.ContextTokens != nil {
+		c.Tokens = *settings.ContextTokens
+	}
+	if settings.AmbientRatio != nil {
+		c.AmbientRatio = *settings.AmbientRatio
+	}
+	return c
+}
+
+// estimateTokens грубо оценивает число токенов в тексте: для русского текста
+// токенизаторы дают примерно один токен на 3 символа
+func estimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/3 + 1
+}
+
+// takeRecent выбирает из сообщений (в хронологическом порядке) самые новые, чья суммарная
+// оценка cost укладывается в budget. Самое новое сообщение берется всегда.
+func takeRecent(
+	messages []*models.MessageDocument,
+	budget int,
+	cost func(*models.MessageDocument) int,
+) []*models.MessageDocument {
+	used := 0
+	start := len(messages)
+	for start > 0 {
+		next := cost(messages[start-1])
+		if used+next > budget && start < len(messages) {
+			break
+		}
+		used += next
+		start--
+	}
+	return messages[start:]
+}
+
+// isAmbient сообщает, что сообщение - часть фонового разговора: его написали не боту, и бот на него не отвечал
+func isAmbient(msg *models.MessageDocument) bool {
+	return !isOwnMessage(msg) && !msg.IsAddressedToBot &&
+		msg.Text != "" && !strings.HasPrefix(msg.Text, "/")
+}
+
+// ambientText возвращает текст сообщения фонового разговора одной строкой, обрезанный до maxAmbientMessageLength
+func ambientText(msg *models.MessageDocument) string {
+	text := strings.Join(strings.Fields(msg.Text), " ")
+	if utf8.RuneCountInString(text) > maxAmbientMessageLength {
+		text = string([]rune(text)[:maxAmbientMessageLength]) + "…"
+	}
+	return text
+}
+
+// ambientLine возвращает строку стенограммы для сообщения фонового разговора
+func ambientLine(replies replyIndex, msg *models.MessageDocument) string {
+	return replies.author(msg) + ": " + ambientText(msg)
+}
+
+// ambientTranscript собирает компактную стенограмму фонового разговора в пределах бюджета
+// и возвращает ее вместе с вошедшими в нее сообщениями. Сообщения, уже показанные в ветке ответов,
+// пропускаются. Пустая строка - фонового разговора нет.
+func ambientTranscript(
+	replies replyIndex,
+	messages []*models.MessageDocument,
+	skip map[messageKey]bool,
+	budget int,
+) (string, []*models.MessageDocument) {
+	const header = "Фоновый разговор в чате (к тебе в нем не обращались, это только контекст, " +
+		"отвечать на эти сообщения не нужно):"
+	budget -= estimateTokens(header) + messageOverheadTokens
+	if budget <= 0 {
+		return "", nil
+	}
+
+	ambient := make([]*models.MessageDocument, 0, len(messages))
+	for _, msg := range messages {
+		if isAmbient(msg) && !skip[keyOf(msg)] {
+			ambient = append(ambient, msg)
+		}
+	}
+	if len(ambient) == 0 {
+		return "", nil
+	}
+
+	ambient = takeRecent(ambient, budget, func(msg *models.MessageDocument) int {
+		return estimateTokens(ambientLine(replies, msg))
+	})
+	// Даже самое новое сообщение не должно выходить за бюджет фонового разговора
+	if len(ambient) == 1 && estimateTokens(ambientLine(replies, ambient[0])) > budget {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	// Несколько сообщений подряд от одного автора склеиваются в одну строку
+	lastAuthor := ""
+	for _, msg := range ambient {
+		author := replies.author(msg)
+		if author == lastAuthor {
+			sb.WriteString(" / " + ambientText(msg))
+			continue
+		}
+		sb.WriteString("\n" + author + ": " + ambientText(msg))
+		lastAuthor = author
+	}
+	return sb.String(), ambient
+}
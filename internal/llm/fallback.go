@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrUnavailable возвращается, когда ни одна модель цепочки не смогла ответить
+var ErrUnavailable = errors.New("LLM недоступна")
+
+// errStreamInterrupted - потоковый ответ оборвался, когда часть его уже была отдана
+var errStreamInterrupted = errors.New("потоковый ответ прерван")
+
+// modelChain возвращает модели в порядке попыток: основная, затем резервные без повторов
+func (c *Client) modelChain(primary string) []string {
+	chain := []string{primary}
+	for _, model := range c.fallbackModels {
+		duplicate := false
+		for _, seen := range chain {
+			duplicate = duplicate || seen == model
+		}
+		if !duplicate {
+			chain = append(chain, model)
+		}
+	}
+	return chain
+}
+
+// complete выполняет запрос через call, повторяя его при временных сбоях и переходя к резервным моделям,
+// если модель так и не ответила. Возвращает ответ и модель, которая его дала.
+// Каждая попытка записывается в лог одной строкой цепочки.
+func (c *Client) complete(
+	ctx context.Context,
+	request ChatRequest,
+	call func(ctx context.Context, request ChatRequest) (*ChatResponse, error),
+) (*ChatResponse, string, error) {
+	var attempts []string
+	defer func() {
+		log.Printf("Цепочка LLM (%s): %s", c.provider.Name(), strings.Join(attempts, " → "))
+	}()
+
+	var lastErr error
+	for _, model := range c.modelChain(request.Model) {
+		request.Model = model
+		for attempt := 0; attempt < c.retry.attempts(); attempt++ {
+			if err := c.breaker.Allow(); err != nil {
+				attempts = append(attempts, model+": выключатель разомкнут")
+				return nil, "", fmt.Errorf("%w: %w", ErrUnavailable, err)
+			}
+
+			response, err := call(ctx, request)
+			if err == nil {
+				c.breaker.Success()
+				attempts = append(attempts, model+": ok")
+				return response, model, nil
+			}
+			lastErr = err
+			attempts = append(attempts, fmt.Sprintf("%s: %s", model, attemptError(err)))
+			if ctx.Err() != nil {
+				// Запрос отменен или истекло время обработки обновления - провайдер тут ни при чем
+				c.breaker.Release()
+				return nil, "", err
+			}
+			if errors.Is(err, errStreamInterrupted) {
+				// Начало ответа уже показано: повтор или другая модель начали бы ответ заново
+				c.breaker.Failure()
+				return nil, "", err
+			}
+
+			if !isRetryable(err) {
+				// Провайдер ответил, просто не смог выполнить именно этот запрос
+				c.breaker.Success()
+				break
+			}
+			if isRateLimited(err) {
+				// 429 - провайдер работает, но просит подождать: выдерживаем Retry-After, не засчитывая сбой
+				c.breaker.Release()
+			} else if c.breaker.Failure() {
+				log.Printf("Провайдер LLM %s отключен после серии ошибок", c.provider.Name())
+			}
+			if attempt == c.retry.attempts()-1 {
+				break
+			}
+
+			delay, ok := c.retry.delay(attempt, err)
+			if !ok {
+				break
+			}
+			if err := sleep(ctx, delay); err != nil {
+				c.breaker.Release()
+				return nil, "", err
+			}
+		}
+
+		if !shouldFallback(lastErr) {
+			break
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %w", ErrUnavailable, lastErr)
+}
+
+// attemptError кратко описывает ошибку попытки для лога цепочки
+func attemptError(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			return fmt.Sprintf("%d (Retry-After %s)", apiErr.StatusCode, apiErr.RetryAfter)
+		}
+		return fmt.Sprintf("%d", apiErr.StatusCode)
+	}
+	return err.Error()
+}
@@ -0,0 +1,311 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// testRetry - повторы без заметных пауз
+var testRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func testRequest(text string) GenerateRequest {
+	return GenerateRequest{
+		BotName: "Жорик",
+		Messages: []*models.MessageDocument{{
+			MessageID: 1, ChatID: -1, FirstName: "Вася", Text: text, IsAddressedToBot: true,
+		}},
+	}
+}
+
+// requestedModels возвращает модели запросов, полученных мок-провайдером
+func requestedModels(provider *MockProvider) []string {
+	var models []string
+	for _, request := range provider.Requests() {
+		models = append(models, request.Model)
+	}
+	return models
+}
+
+func TestClientRetryAndFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     []string
+		attempts   int
+		fallback   []string
+		want       string
+		wantErr    bool
+		wantModels []string
+	}{
+		{
+			name:       "повтор после 503",
+			script:     []string{"!503", "ответ"},
+			want:       "ответ",
+			wantModels: []string{"main", "main"},
+		},
+		{
+			name:       "резервная модель после исчерпания повторов",
+			script:     []string{"!503", "!502", "ответ"},
+			attempts:   2,
+			fallback:   []string{"reserve"},
+			want:       "ответ",
+			wantModels: []string{"main", "main", "reserve"},
+		},
+		{
+			name:       "слишком долгий Retry-After - сразу резервная модель",
+			script:     []string{"!429 60", "ответ"},
+			fallback:   []string{"reserve"},
+			want:       "ответ",
+			wantModels: []string{"main", "reserve"},
+		},
+		{
+			name:       "ошибка запроса не повторяется, но пробуется другая модель",
+			script:     []string{"!400", "ответ"},
+			fallback:   []string{"reserve"},
+			want:       "ответ",
+			wantModels: []string{"main", "reserve"},
+		},
+		{
+			name:       "ошибка авторизации не повторяется и не переходит к другой модели",
+			script:     []string{"!401"},
+			fallback:   []string{"reserve"},
+			wantErr:    true,
+			wantModels: []string{"main"},
+		},
+		{
+			name:       "резервная модель не дублирует основную",
+			script:     []string{"!503"},
+			attempts:   1,
+			fallback:   []string{"main", "reserve"},
+			wantErr:    true,
+			wantModels: []string{"main", "reserve"},
+		},
+		{
+			name:       "все попытки неудачны",
+			script:     []string{"!500"},
+			attempts:   2,
+			wantErr:    true,
+			wantModels: []string{"main", "main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewMockProvider(tt.script...)
+			retry := testRetry
+			if tt.attempts > 0 {
+				retry.MaxAttempts = tt.attempts
+			}
+			client := newTestClient(t, provider, ClientOptions{FallbackModels: tt.fallback, Retry: retry})
+
+			got, err := client.GenerateResponse(context.Background(), testRequest("привет"))
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnavailable) {
+					t.Errorf("ошибка = %v, ожидалась ErrUnavailable", err)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("ответ = %q, %v, ожидалось %q", got, err, tt.want)
+			}
+			if models := requestedModels(provider); !slices.Equal(models, tt.wantModels) {
+				t.Errorf("запросы к моделям %v, ожидалось %v", models, tt.wantModels)
+			}
+		})
+	}
+}
+
+func TestClientStreamFallback(t *testing.T) {
+	provider := NewMockProvider("!503", "первый второй")
+	client := newTestClient(t, provider, ClientOptions{FallbackModels: []string{"reserve"}, Retry: RetryPolicy{MaxAttempts: 1}})
+
+	var deltas []string
+	got, err := client.StreamResponse(context.Background(), testRequest("привет"), func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || got != "первый второй" {
+		t.Fatalf("ответ = %q, %v", got, err)
+	}
+	if !slices.Equal(deltas, []string{"первый ", "второй"}) {
+		t.Errorf("фрагменты %q", deltas)
+	}
+	if models := requestedModels(provider); !slices.Equal(models, []string{"main", "reserve"}) {
+		t.Errorf("запросы к моделям %v", models)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	provider := NewMockProvider("!503")
+	client := newTestClient(t, provider, ClientOptions{
+		Retry:   RetryPolicy{MaxAttempts: 1},
+		Breaker: NewCircuitBreaker(2, time.Hour),
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GenerateResponse(context.Background(), testRequest("привет"))
+		if !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("запрос %d: ошибка = %v, ожидалась ErrUnavailable", i+1, err)
+		}
+		if open := errors.Is(err, ErrCircuitOpen); open != (i == 2) {
+			t.Errorf("запрос %d: выключатель разомкнут = %t", i+1, open)
+		}
+	}
+	// Третий запрос до провайдера не дошел
+	if calls := len(provider.Requests()); calls != 2 {
+		t.Errorf("запросов к провайдеру %d, ожидалось 2", calls)
+	}
+}
+
+func TestClientRateLimitDoesNotOpenBreaker(t *testing.T) {
+	provider := NewMockProvider("!429 0")
+	client := newTestClient(t, provider, ClientOptions{
+		Retry:   testRetry,
+		Breaker: NewCircuitBreaker(2, time.Hour),
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GenerateResponse(context.Background(), testRequest("привет"))
+		if !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("запрос %d: ошибка = %v, ожидалась ErrUnavailable без срабатывания выключателя", i+1, err)
+		}
+	}
+	// Все повторы обоих запросов дошли до провайдера
+	if calls := len(provider.Requests()); calls != 2*testRetry.MaxAttempts {
+		t.Errorf("запросов к провайдеру %d, ожидалось %d", calls, 2*testRetry.MaxAttempts)
+	}
+}
+
+// blockingProvider отвечает только после отмены запроса
+type blockingProvider struct{}
+
+func (blockingProvider) Name() string { return "blocking" }
+
+func (blockingProvider) Generate(ctx context.Context, _ ChatRequest) (*ChatResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClientCancelledProbeReleasesBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Millisecond)
+	breaker.Failure()
+	time.Sleep(2 * time.Millisecond)
+
+	client := newTestClient(t, blockingProvider{}, ClientOptions{Retry: RetryPolicy{MaxAttempts: 1}, Breaker: breaker})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.GenerateResponse(ctx, testRequest("привет")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ошибка = %v, ожидалась отмена", err)
+	}
+
+	// Отмененный пробный запрос не должен занимать место пробного навсегда
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("после отмены пробного запроса Allow = %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = time.Hour
+	// expire делает вид, что пауза выключателя уже прошла
+	expire := func(b *CircuitBreaker) { b.openedAt = time.Now().Add(-2 * cooldown) }
+
+	tests := []struct {
+		name    string
+		steps   func(b *CircuitBreaker)
+		wantErr bool
+	}{
+		{
+			name:  "сбоев меньше порога",
+			steps: func(b *CircuitBreaker) { b.Failure(); b.Failure() },
+		},
+		{
+			name:    "порог достигнут",
+			steps:   func(b *CircuitBreaker) { b.Failure(); b.Failure(); b.Failure() },
+			wantErr: true,
+		},
+		{
+			name:  "успех обнуляет счетчик",
+			steps: func(b *CircuitBreaker) { b.Failure(); b.Failure(); b.Success(); b.Failure() },
+		},
+		{
+			name: "после паузы пропускается пробный запрос",
+			steps: func(b *CircuitBreaker) {
+				b.Failure()
+				b.Failure()
+				b.Failure()
+				expire(b)
+			},
+		},
+		{
+			name: "второй запрос во время пробного не пропускается",
+			steps: func(b *CircuitBreaker) {
+				b.Failure()
+				b.Failure()
+				b.Failure()
+				expire(b)
+				_ = b.Allow()
+			},
+			wantErr: true,
+		},
+		{
+			name: "неудачный пробный запрос снова отключает провайдера",
+			steps: func(b *CircuitBreaker) {
+				b.Failure()
+				b.Failure()
+				b.Failure()
+				expire(b)
+				_ = b.Allow()
+				b.Failure()
+			},
+			wantErr: true,
+		},
+		{
+			name: "успешный пробный запрос открывает доступ",
+			steps: func(b *CircuitBreaker) {
+				b.Failure()
+				b.Failure()
+				b.Failure()
+				expire(b)
+				_ = b.Allow()
+				b.Success()
+			},
+		},
+		{
+			name: "отмененный пробный запрос освобождает место",
+			steps: func(b *CircuitBreaker) {
+				b.Failure()
+				b.Failure()
+				b.Failure()
+				expire(b)
+				_ = b.Allow()
+				b.Release()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(3, cooldown)
+			tt.steps(b)
+			if err := b.Allow(); (err != nil) != tt.wantErr {
+				t.Errorf("Allow = %v, ожидалась ошибка: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(0, time.Hour)
+	if b != nil {
+		t.Fatal("выключатель с нулевым порогом должен быть nil")
+	}
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+	b.Release()
+	if err := b.Allow(); err != nil {
+		t.Errorf("nil-выключатель вернул %v", err)
+	}
+}
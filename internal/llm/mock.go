@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockProvider - детерминированный провайдер для тестов и разработки без сети.
+// Отвечает репликами сценария по кругу, а без сценария повторяет последнее сообщение пользователя.
+// Реплика вида "!503" или "!429 5" изображает ошибку API с этим кодом (и Retry-After в секундах).
+// Все полученные запросы сохраняются и доступны через Requests.
+type MockProvider struct {
+	script []string
+
+	mu       sync.Mutex
+	calls    int
+	requests []ChatRequest
+}
+
+// NewMockProvider создает мок-провайдер с заданным сценарием ответов
+func NewMockProvider(script ...string) *MockProvider {
+	return &MockProvider{script: script}
+}
+
+// LoadMockScript читает сценарий мок-провайдера из файла: каждая непустая строка - отдельный ответ,
+// последовательность \n внутри строки заменяется переводом строки
+func LoadMockScript(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения сценария %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var script []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			script = append(script, strings.ReplaceAll(line, `\n`, "\n"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения сценария %s: %w", path, err)
+	}
+	return script, nil
+}
+
+func (p *MockProvider) Name() string {
+	return ProviderMock
+}
+
+func (p *MockProvider) Generate(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.respond(request)
+}
+
+// Stream отдает ответ по словам
+func (p *MockProvider) Stream(
+	ctx context.Context,
+	request ChatRequest,
+	onDelta func(delta string) error,
+) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response, err := p.respond(request)
+	if err != nil {
+		return nil, err
+	}
+	for _, delta := range strings.SplitAfter(response.Choices[0].Message.Content, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// Requests возвращает копию всех полученных запросов в порядке поступления
+func (p *MockProvider) Requests() []ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ChatRequest(nil), p.requests...)
+}
+
+// respond запоминает запрос и формирует очередной ответ сценария
+func (p *MockProvider) respond(request ChatRequest) (*ChatResponse, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, request)
+	call := p.calls
+	p.calls++
+	p.mu.Unlock()
+
+	text := ""
+	if len(p.script) > 0 {
+		text = p.script[call%len(p.script)]
+	} else {
+		text = "Эхо: " + lastUserMessage(request.Messages)
+	}
+	if err := mockError(text); err != nil {
+		return nil, err
+	}
+
+	prompt := 0
+	for _, msg := range request.Messages {
+		prompt += estimateTokens(msg.Content)
+	}
+	completion := estimateTokens(text)
+
+	return &ChatResponse{
+		ID:      fmt.Sprintf("mock-%d", call+1),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+		Choices: []Choice{{
+			Message:      Message{Role: "assistant", Content: text},
+			FinishReason: "stop",
+		}},
+		Usage: Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+		},
+	}, nil
+}
+
+// mockError возвращает ошибку API, если реплика сценария имеет вид "!<код> [Retry-After в секундах]"
+func mockError(line string) error {
+	if !strings.HasPrefix(line, "!") {
+		return nil
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "!"))
+	if len(fields) == 0 {
+		return nil
+	}
+	status, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil
+	}
+
+	apiErr := &APIError{StatusCode: status, Body: "ошибка из сценария мок-провайдера"}
+	if len(fields) > 1 {
+		if seconds, err := strconv.Atoi(fields[1]); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return apiErr
+}
+
+// lastUserMessage возвращает текст последнего сообщения с ролью user
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
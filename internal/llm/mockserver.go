@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewMockServer возвращает HTTP обработчик, который изображает LLM API поверх провайдера
+// (обычно MockProvider), чтобы запускать бота целиком без внешних сервисов:
+//
+//   - POST /v1/chat/completions и /chat/completions - OpenAI-совместимый API (для LLM_PROVIDER=openai),
+//     с "stream": true ответ отдается как server-sent events
+//   - POST /api/chat - нативный API Ollama (для LLM_PROVIDER=ollama)
+func NewMockServer(provider Provider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", openAICompletionsHandler(provider))
+	mux.HandleFunc("POST /chat/completions", openAICompletionsHandler(provider))
+	mux.HandleFunc("POST /api/chat", ollamaChatHandler(provider))
+	return mux
+}
+
+func openAICompletionsHandler(provider Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ChatRequest
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeMockStatus(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+			return
+		}
+
+		streamer, canStream := provider.(StreamProvider)
+		if !request.Stream || !canStream {
+			response, err := provider.Generate(r.Context(), request.ChatRequest)
+			if err != nil {
+				writeMockError(w, err)
+				return
+			}
+			writeMockJSON(w, response)
+			return
+		}
+
+		// Потоковый ответ - server-sent events с фрагментами chat.completion.chunk
+		flusher, _ := w.(http.Flusher)
+		started := false
+		writeEvent := func(value any) error {
+			if !started {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				started = true
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		}
+
+		id := fmt.Sprintf("mock-stream-%d", time.Now().UnixNano())
+		response, err := streamer.Stream(r.Context(), request.ChatRequest, func(delta string) error {
+			return writeEvent(openAIChunk(id, request.Model, Message{Role: "assistant", Content: delta}, "", nil))
+		})
+		if err != nil {
+			if !started {
+				writeMockError(w, err)
+				return
+			}
+			// Заголовки уже отправлены, поэтому ошибка передается событием потока, как у OpenRouter
+			_ = writeEvent(map[string]any{"error": map[string]string{"message": err.Error()}})
+			return
+		}
+
+		_ = writeEvent(openAIChunk(id, response.Model, Message{}, response.Choices[0].FinishReason, &response.Usage))
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+// openAIChunk формирует фрагмент потокового ответа chat/completions
+func openAIChunk(id, model string, delta Message, finishReason string, usage *Usage) map[string]any {
+	choice := map[string]any{"index": 0, "delta": delta, "finish_reason": nil}
+	if finishReason != "" {
+		choice["finish_reason"] = finishReason
+	}
+	return map[string]any{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []any{choice},
+		"usage":   usage,
+	}
+}
+
+func ollamaChatHandler(provider Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ollamaChatRequest
+			Stream *bool `json:"stream"` // Как и в Ollama, без явного stream: false ответ потоковый
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeMockStatus(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+			return
+		}
+
+		chatRequest := ChatRequest{
+			Model:          request.Model,
+			Messages:       request.Messages,
+			SamplingParams: request.Options.samplingParams(),
+		}
+
+		streamer, canStream := provider.(StreamProvider)
+		if !canStream || (request.Stream != nil && !*request.Stream) {
+			response, err := provider.Generate(r.Context(), chatRequest)
+			if err != nil {
+				writeMockError(w, err)
+				return
+			}
+			writeMockJSON(w, ollamaResponseFrom(response, response.Choices[0].Message.Content))
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		started := false
+		response, err := streamer.Stream(r.Context(), chatRequest, func(delta string) error {
+			if !started {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				started = true
+			}
+			if err := encoder.Encode(ollamaChatResponse{
+				Model:     chatRequest.Model,
+				CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+				Message:   Message{Role: "assistant", Content: delta},
+			}); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			if !started {
+				writeMockError(w, err)
+				return
+			}
+			// Заголовки уже отправлены, поэтому ошибка передается последней строкой потока
+			_ = encoder.Encode(ollamaChatResponse{Error: err.Error()})
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		_ = encoder.Encode(ollamaResponseFrom(response, ""))
+	}
+}
+
+// ollamaResponseFrom переводит ответ chat/completions в завершающий ответ Ollama с текстом text
+func ollamaResponseFrom(response *ChatResponse, text string) ollamaChatResponse {
+	return ollamaChatResponse{
+		Model:           response.Model,
+		CreatedAt:       time.Unix(response.Created, 0).UTC().Format(time.RFC3339Nano),
+		Message:         Message{Role: "assistant", Content: text},
+		Done:            true,
+		DoneReason:      response.Choices[0].FinishReason,
+		PromptEvalCount: response.Usage.PromptTokens,
+		EvalCount:       response.Usage.CompletionTokens,
+	}
+}
+
+func writeMockJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Ошибка отправки ответа мок-сервера: %v", err)
+	}
+}
+
+// writeMockError отвечает ошибкой в формате, который понимают и OpenAI-совместимые клиенты, и клиенты Ollama.
+// Ошибки API из сценария передаются с их кодом и Retry-After.
+func writeMockError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		status = apiErr.StatusCode
+		if apiErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(apiErr.RetryAfter.Seconds())))
+		}
+	}
+	writeMockStatus(w, status, err.Error())
+}
+
+func writeMockStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": message,
+	})
+}
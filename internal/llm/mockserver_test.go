@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMockServerProviders запускает мок-сервер со сценарием script и возвращает клиентов обоих API к нему
+func newMockServerProviders(t *testing.T, script ...string) map[string]StreamProvider {
+	t.Helper()
+	server := httptest.NewServer(NewMockServer(NewMockProvider(script...)))
+	t.Cleanup(server.Close)
+	return map[string]StreamProvider{
+		ProviderOpenAI: NewOpenAIProvider(server.URL+"/v1", ""),
+		ProviderOllama: NewOllamaProvider(server.URL),
+	}
+}
+
+func TestMockServerGenerate(t *testing.T) {
+	for name, provider := range newMockServerProviders(t) {
+		t.Run(name, func(t *testing.T) {
+			response, err := provider.Generate(context.Background(), ChatRequest{
+				Model:    "main",
+				Messages: []Message{{Role: "system", Content: "промпт"}, {Role: "user", Content: "привет"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Choices[0].Message.Content; got != "Эхо: привет" {
+				t.Errorf("ответ %q", got)
+			}
+			if response.Usage.PromptTokens == 0 || response.Usage.CompletionTokens == 0 {
+				t.Errorf("расход токенов не передан: %+v", response.Usage)
+			}
+		})
+	}
+}
+
+func TestMockServerStream(t *testing.T) {
+	for name, provider := range newMockServerProviders(t, "первый второй третий") {
+		t.Run(name, func(t *testing.T) {
+			var deltas []string
+			response, err := provider.Stream(context.Background(), ChatRequest{Model: "main"}, func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(deltas, []string{"первый ", "второй ", "третий"}) {
+				t.Errorf("фрагменты %q", deltas)
+			}
+			if got := response.Choices[0]; got.Message.Content != "первый второй третий" || got.FinishReason != "stop" {
+				t.Errorf("ответ %+v", got)
+			}
+		})
+	}
+}
+
+func TestMockServerErrors(t *testing.T) {
+	for name, provider := range newMockServerProviders(t, "!429 3") {
+		t.Run(name, func(t *testing.T) {
+			_, err := provider.Generate(context.Background(), ChatRequest{Model: "main"})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 3*time.Second {
+				t.Errorf("ошибка = %v, ожидалась APIError 429 с Retry-After", err)
+			}
+
+			// Ошибка до начала потока передается кодом ответа, а не событием потока
+			_, err = provider.Stream(context.Background(), ChatRequest{Model: "main"}, func(string) error { return nil })
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("ошибка потока = %v, ожидалась APIError 429", err)
+			}
+		})
+	}
+}
+
+func TestMockServerBadRequest(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(NewMockProvider()))
+	t.Cleanup(server.Close)
+
+	for _, path := range []string{"/v1/chat/completions", "/api/chat"} {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader("{"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: код ответа %d на некорректный JSON", path, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/v1/chat/completions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("код ответа %d на GET", resp.StatusCode)
+	}
+}
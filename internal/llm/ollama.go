@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// OllamaProvider работает с нативным API Ollama (/api/chat)
+type OllamaProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOllamaProvider создает провайдера для сервера Ollama по адресу baseURL (например, http://localhost:11434)
+func NewOllamaProvider(baseURL string) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		// Локальные модели отвечают заметно дольше облачных, особенно при первой загрузке модели
+		httpClient: newHTTPClient(5 * time.Minute),
+	}
+}
+
+// ollamaChatRequest - запрос к /api/chat
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+// ollamaOptions - параметры генерации в терминах Ollama
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"` // Аналог max_tokens
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// newOllamaOptions переводит общие параметры генерации в параметры Ollama (nil - ничего не задано)
+func newOllamaOptions(p models.SamplingParams) *ollamaOptions {
+	if p.IsZero() {
+		return nil
+	}
+	return &ollamaOptions{
+		Temperature:      p.Temperature,
+		TopP:             p.TopP,
+		NumPredict:       p.MaxTokens,
+		Stop:             p.Stop,
+		PresencePenalty:  p.PresencePenalty,
+		FrequencyPenalty: p.FrequencyPenalty,
+		Seed:             p.Seed,
+	}
+}
+
+// samplingParams переводит параметры Ollama обратно в общий формат
+func (o *ollamaOptions) samplingParams() models.SamplingParams {
+	if o == nil {
+		return models.SamplingParams{}
+	}
+	return models.SamplingParams{
+		Temperature:      o.Temperature,
+		TopP:             o.TopP,
+		MaxTokens:        o.NumPredict,
+		Stop:             o.Stop,
+		PresencePenalty:  o.PresencePenalty,
+		FrequencyPenalty: o.FrequencyPenalty,
+		Seed:             o.Seed,
+	}
+}
+
+// ollamaChatResponse - ответ /api/chat (при потоковой генерации - одна строка NDJSON)
+type ollamaChatResponse struct {
+	Model           string  `json:"model"`
+	CreatedAt       string  `json:"created_at"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason,omitempty"`
+	PromptEvalCount int     `json:"prompt_eval_count,omitempty"`
+	EvalCount       int     `json:"eval_count,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+func (p *OllamaProvider) Generate(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	resp, err := p.post(ctx, request, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+	return response.toChatResponse(response.Message.Content), nil
+}
+
+func (p *OllamaProvider) Stream(
+	ctx context.Context,
+	request ChatRequest,
+	onDelta func(delta string) error,
+) (*ChatResponse, error) {
+	resp, err := p.post(ctx, request, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Ollama отдает поток как NDJSON: по объекту на строку, последний - с done = true
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга фрагмента ответа: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama вернула ошибку: %s", chunk.Error)
+		}
+		if delta := chunk.Message.Content; delta != "" {
+			text.WriteString(delta)
+			if err := onDelta(delta); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			return chunk.toChatResponse(text.String()), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	return nil, fmt.Errorf("поток ответа ollama оборвался до завершения")
+}
+
+// post отправляет запрос к /api/chat и проверяет статус ответа
+func (p *OllamaProvider) post(ctx context.Context, request ChatRequest, stream bool) (*http.Response, error) {
+	body := ollamaChatRequest{
+		Model:    request.Model,
+		Messages: request.Messages,
+		Stream:   stream,
+		Options:  newOllamaOptions(request.SamplingParams),
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка кодирования JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения HTTP запроса: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, data)
+	}
+	return resp, nil
+}
+
+// toChatResponse переводит ответ Ollama в формат chat/completions
+func (r *ollamaChatResponse) toChatResponse(text string) *ChatResponse {
+	finishReason := r.DoneReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	response := &ChatResponse{
+		Object: "chat.completion",
+		Model:  r.Model,
+		Choices: []Choice{{
+			Message:      Message{Role: "assistant", Content: text},
+			FinishReason: finishReason,
+		}},
+		Usage: Usage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		},
+	}
+	if created, err := time.Parse(time.RFC3339Nano, r.CreatedAt); err == nil {
+		response.Created = created.Unix()
+	}
+	return response
+}
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+func TestOllamaGenerate(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK, nil, `{
+		"model": "llama3", "created_at": "2025-01-01T12:00:00.5Z",
+		"message": {"role": "assistant", "content": "ответ"},
+		"done": true, "prompt_eval_count": 10, "eval_count": 3
+	}`)
+
+	temperature, maxTokens := 0.5, 100
+	response, err := NewOllamaProvider(server.URL+"/").Generate(context.Background(), ChatRequest{
+		Model:    "llama3",
+		Messages: []Message{{Role: "user", Content: "привет"}},
+		SamplingParams: models.SamplingParams{
+			Temperature: &temperature,
+			MaxTokens:   &maxTokens,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	choice := response.Choices[0]
+	if choice.Message.Content != "ответ" || choice.FinishReason != "stop" {
+		t.Errorf("ответ %+v", choice)
+	}
+	if response.Usage != (Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}) {
+		t.Errorf("расход токенов %+v", response.Usage)
+	}
+	if response.Created != time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("время ответа %d", response.Created)
+	}
+
+	if (*received)["path"] != "/api/chat" || (*received)["stream"] != false {
+		t.Errorf("запрос %v", *received)
+	}
+	options, _ := (*received)["options"].(map[string]any)
+	if options["temperature"] != 0.5 || options["num_predict"] != float64(100) {
+		t.Errorf("параметры генерации %v", options)
+	}
+}
+
+func TestOllamaGenerateWithoutOptions(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK, nil, `{"message": {"content": "ответ"}, "done": true}`)
+
+	if _, err := NewOllamaProvider(server.URL).Generate(context.Background(), ChatRequest{Model: "llama3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := (*received)["options"]; ok {
+		t.Errorf("пустые параметры генерации переданы в запросе: %v", *received)
+	}
+}
+
+func TestOllamaError(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusNotFound, nil, `{"error": "model not found"}`)
+
+	_, err := NewOllamaProvider(server.URL).Generate(context.Background(), ChatRequest{Model: "llama3"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("ошибка = %v, ожидалась APIError 404", err)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantDeltas []string
+		wantErr    string
+	}{
+		{
+			name: "полный поток",
+			body: `{"model":"llama3","message":{"role":"assistant","content":"при"},"done":false}` + "\n" +
+				`{"model":"llama3","message":{"role":"assistant","content":"вет"},"done":false}` + "\n" +
+				`{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":5,"eval_count":2}` + "\n",
+			want:       "привет",
+			wantDeltas: []string{"при", "вет"},
+		},
+		{
+			name: "ошибка во время генерации",
+			body: `{"message":{"content":"при"},"done":false}` + "\n" +
+				`{"error":"out of memory"}` + "\n",
+			wantDeltas: []string{"при"},
+			wantErr:    "out of memory",
+		},
+		{
+			name:       "поток оборвался",
+			body:       `{"message":{"content":"при"},"done":false}` + "\n",
+			wantDeltas: []string{"при"},
+			wantErr:    "оборвался",
+		},
+		{
+			name:    "некорректная строка",
+			body:    "не json\n",
+			wantErr: "ошибка парсинга фрагмента",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := newTestServer(t, http.StatusOK, http.Header{"Content-Type": {"application/x-ndjson"}}, tt.body)
+
+			var deltas []string
+			response, err := NewOllamaProvider(server.URL).Stream(context.Background(), ChatRequest{Model: "llama3"},
+				func(delta string) error {
+					deltas = append(deltas, delta)
+					return nil
+				})
+			if !slices.Equal(deltas, tt.wantDeltas) {
+				t.Errorf("фрагменты %q, ожидалось %q", deltas, tt.wantDeltas)
+			}
+			if (*received)["stream"] != true {
+				t.Errorf("в запросе нет stream: true: %v", *received)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ошибка = %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Choices[0]; got.Message.Content != tt.want || got.FinishReason != "length" {
+				t.Errorf("ответ %+v", got)
+			}
+			if response.Usage.TotalTokens != 7 {
+				t.Errorf("расход токенов %+v", response.Usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider работает с OpenAI-совместимым chat/completions API (OpenRouter, OpenAI, vLLM, llama.cpp и т.п.)
+type OpenAIProvider struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	// streamClient - клиент для потоковых ответов: длинный ответ может идти дольше обычного таймаута
+	streamClient *http.Client
+}
+
+// NewOpenAIProvider создает провайдера для API по адресу baseURL (например, https://openrouter.ai/api/v1).
+// apiKey может быть пустым для локальных серверов без авторизации.
+func NewOpenAIProvider(baseURL, apiKey string) *OpenAIProvider {
+	return &OpenAIProvider{
+		apiKey:       apiKey,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   newHTTPClient(60 * time.Second),
+		streamClient: newHTTPClient(5 * time.Minute),
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+func (p *OpenAIProvider) Generate(ctx context.Context, request ChatRequest) (*ChatResponse, error) {
+	resp, err := p.post(ctx, p.httpClient, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	var response ChatResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+
+	return &response, nil
+}
+
+// openAIStreamRequest - запрос с потоковой передачей ответа (server-sent events)
+type openAIStreamRequest struct {
+	ChatRequest
+	Stream        bool                `json:"stream"`
+	StreamOptions openAIStreamOptions `json:"stream_options"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Последний фрагмент содержит расход токенов
+}
+
+// openAIStreamChunk - один фрагмент потокового ответа chat/completions
+type openAIStreamChunk struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	// Error - ошибка, случившаяся после начала ответа (так делает OpenRouter)
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) Stream(
+	ctx context.Context,
+	request ChatRequest,
+	onDelta func(delta string) error,
+) (*ChatResponse, error) {
+	resp, err := p.post(ctx, p.streamClient, openAIStreamRequest{
+		ChatRequest:   request,
+		Stream:        true,
+		StreamOptions: openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &ChatResponse{Object: "chat.completion", Model: request.Model}
+	finishReason := ""
+	var text strings.Builder
+
+	// Поток - server-sent events: строки "data: {...}", завершается "data: [DONE]".
+	// Строки-комментарии (": OPENROUTER PROCESSING") и пустые строки между событиями пропускаем.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			response.Choices = []Choice{{
+				Message:      Message{Role: "assistant", Content: text.String()},
+				FinishReason: finishReason,
+			}}
+			return response, nil
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга фрагмента ответа: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("API вернул ошибку во время генерации: %s", chunk.Error.Message)
+		}
+
+		if chunk.ID != "" {
+			response.ID = chunk.ID
+		}
+		if chunk.Created != 0 {
+			response.Created = chunk.Created
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if delta := choice.Delta.Content; delta != "" {
+				text.WriteString(delta)
+				if err := onDelta(delta); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	return nil, fmt.Errorf("поток ответа оборвался до завершения")
+}
+
+// post отправляет запрос к chat/completions и проверяет статус ответа
+func (p *OpenAIProvider) post(ctx context.Context, httpClient *http.Client, request any) (*http.Response, error) {
+	// Конвертируем запрос в JSON
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка кодирования JSON: %w", err)
+	}
+
+	url := p.baseURL + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	// Заголовки атрибуции OpenRouter, остальные API их игнорируют
+	req.Header.Set("HTTP-Referer", "https://github.com/semyon-ancherbak/sueta")
+	req.Header.Set("X-Title", "Sueta Telegram Bot")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения HTTP запроса: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+		}
+		return nil, newAPIError(resp, body)
+	}
+	return resp, nil
+}
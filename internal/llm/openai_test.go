@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer запускает HTTP сервер, который отвечает body с кодом status и запоминает тело запроса
+func newTestServer(t *testing.T, status int, header http.Header, body string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	received := new(map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			t.Errorf("некорректный JSON запроса: %v", err)
+		}
+		(*received)["path"] = r.URL.Path
+		(*received)["authorization"] = r.Header.Get("Authorization")
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestOpenAIGenerate(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK, nil, `{
+		"id": "gen-1", "object": "chat.completion", "model": "main",
+		"choices": [{"message": {"role": "assistant", "content": "ответ"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}
+	}`)
+
+	provider := NewOpenAIProvider(server.URL+"/v1/", "ключ")
+	response, err := provider.Generate(context.Background(), ChatRequest{
+		Model:    "main",
+		Messages: []Message{{Role: "user", Content: "привет"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Choices[0].Message.Content != "ответ" || response.Usage.PromptTokens != 10 {
+		t.Errorf("ответ %+v", response)
+	}
+	if (*received)["path"] != "/v1/chat/completions" || (*received)["authorization"] != "Bearer ключ" {
+		t.Errorf("запрос %v", *received)
+	}
+	if _, ok := (*received)["stream"]; ok {
+		t.Error("обычный запрос передан как потоковый")
+	}
+}
+
+func TestOpenAIError(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusTooManyRequests, http.Header{"Retry-After": {"7"}}, `{"error": "rate limit"}`)
+
+	_, err := NewOpenAIProvider(server.URL, "").Generate(context.Background(), ChatRequest{Model: "main"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ошибка = %v, ожидалась APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("ошибка %+v", apiErr)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantDeltas []string
+		wantErr    string
+	}{
+		{
+			name: "полный поток",
+			body: ": OPENROUTER PROCESSING\n\n" +
+				`data: {"id":"gen-1","created":100,"model":"main","choices":[{"delta":{"role":"assistant","content":"при"}}]}` + "\n\n" +
+				`data: {"id":"gen-1","choices":[{"delta":{"content":"вет"}}]}` + "\n\n" +
+				`data: {"id":"gen-1","choices":[{"delta":{},"finish_reason":"length"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}` + "\n\n" +
+				"data: [DONE]\n\n",
+			want:       "привет",
+			wantDeltas: []string{"при", "вет"},
+		},
+		{
+			name: "ошибка во время генерации",
+			body: `data: {"choices":[{"delta":{"content":"при"}}]}` + "\n\n" +
+				`data: {"error":{"message":"модель перегружена"}}` + "\n\n",
+			wantDeltas: []string{"при"},
+			wantErr:    "модель перегружена",
+		},
+		{
+			name:       "поток оборвался",
+			body:       `data: {"choices":[{"delta":{"content":"при"}}]}` + "\n\n",
+			wantDeltas: []string{"при"},
+			wantErr:    "оборвался",
+		},
+		{
+			name:    "некорректный фрагмент",
+			body:    "data: {не json}\n\n",
+			wantErr: "ошибка парсинга фрагмента",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := newTestServer(t, http.StatusOK, http.Header{"Content-Type": {"text/event-stream"}}, tt.body)
+
+			var deltas []string
+			response, err := NewOpenAIProvider(server.URL, "").Stream(context.Background(), ChatRequest{Model: "main"},
+				func(delta string) error {
+					deltas = append(deltas, delta)
+					return nil
+				})
+			if !slices.Equal(deltas, tt.wantDeltas) {
+				t.Errorf("фрагменты %q, ожидалось %q", deltas, tt.wantDeltas)
+			}
+			if (*received)["stream"] != true {
+				t.Errorf("в запросе нет stream: true: %v", *received)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ошибка = %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := response.Choices[0]; got.Message.Content != tt.want || got.FinishReason != "length" {
+				t.Errorf("ответ %+v", got)
+			}
+			if response.ID != "gen-1" || response.Created != 100 || response.Usage.TotalTokens != 7 {
+				t.Errorf("ответ %+v", response)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamCallbackError(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusOK, nil,
+		`data: {"choices":[{"delta":{"content":"при"}}]}`+"\n\n"+"data: [DONE]\n\n")
+	stop := errors.New("хватит")
+
+	_, err := NewOpenAIProvider(server.URL, "").Stream(context.Background(), ChatRequest{Model: "main"},
+		func(string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("ошибка = %v, ожидалась ошибка onDelta", err)
+	}
+}
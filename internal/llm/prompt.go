@@ -0,0 +1,125 @@
+package llm
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultPrompt - встроенный системный промпт, используется если внешний файл не задан или недоступен
+//
+//go:embed prompt.txt
+var defaultPrompt string
+
+// PromptData - переменные, доступные в шаблоне системного промпта
+type PromptData struct {
+	BotName      string
+	ChatTitle    string
+	Date         string
+	Participants []string
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// PromptStore хранит шаблон системного промпта и умеет перечитывать его без перезапуска
+type PromptStore struct {
+	path string
+
+	mu   sync.RWMutex
+	tmpl *template.Template
+}
+
+// NewPromptStore загружает шаблон из файла path. Если path пуст, используется встроенный prompt.txt.
+func NewPromptStore(path string) (*PromptStore, error) {
+	store := &PromptStore{path: path}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload перечитывает шаблон. При ошибке продолжает использоваться ранее загруженный шаблон.
+func (s *PromptStore) Reload() error {
+	text, source := defaultPrompt, "встроенный prompt.txt"
+	if s.path != "" {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			if s.current() != nil {
+				return fmt.Errorf("ошибка чтения промпта %s: %w", s.path, err)
+			}
+			log.Printf("Не удалось прочитать промпт %s, используем встроенный: %v", s.path, err)
+		} else {
+			text, source = string(data), s.path
+		}
+	}
+
+	tmpl, err := parsePrompt(text)
+	if err != nil {
+		return fmt.Errorf("ошибка разбора промпта (%s): %w", source, err)
+	}
+
+	s.mu.Lock()
+	s.tmpl = tmpl
+	s.mu.Unlock()
+
+	log.Printf("Системный промпт загружен: %s", source)
+	return nil
+}
+
+// Render подставляет переменные в шаблон системного промпта.
+// Непустой persona заменяет общий шаблон (например, персона, заданная для конкретного чата).
+func (s *PromptStore) Render(persona string, data PromptData) (string, error) {
+	if persona == "" {
+		return renderPrompt(s.current(), data)
+	}
+
+	tmpl, err := parsePrompt(persona)
+	if err != nil {
+		return "", fmt.Errorf("ошибка разбора персоны чата: %w", err)
+	}
+	return renderPrompt(tmpl, data)
+}
+
+// ValidatePrompt проверяет, что текст является корректным шаблоном промпта
+func ValidatePrompt(text string) error {
+	tmpl, err := parsePrompt(text)
+	if err != nil {
+		return err
+	}
+	_, err = renderPrompt(tmpl, PromptData{})
+	return err
+}
+
+func (s *PromptStore) current() *template.Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tmpl
+}
+
+func parsePrompt(text string) (*template.Template, error) {
+	return template.New("prompt").Funcs(promptFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func renderPrompt(tmpl *template.Template, data PromptData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("ошибка подстановки переменных в промпт: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// formatPromptDate форматирует дату для промпта
+func formatPromptDate(t time.Time) string {
+	months := []string{
+		"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря",
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), months[t.Month()-1], t.Year())
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Поддерживаемые провайдеры LLM
+const (
+	ProviderOpenAI = "openai" // Любой OpenAI-совместимый API: OpenRouter, OpenAI, vLLM, llama.cpp
+	ProviderOllama = "ollama" // Нативный API Ollama
+	ProviderMock   = "mock"   // Детерминированный сценарий для тестов, без сети
+)
+
+// DefaultOpenAIBaseURL - адрес OpenAI-совместимого API по умолчанию (OpenRouter)
+const DefaultOpenAIBaseURL = "https://openrouter.ai/api/v1"
+
+// DefaultOllamaBaseURL - адрес локального сервера Ollama по умолчанию
+const DefaultOllamaBaseURL = "http://localhost:11434"
+
+// Provider выполняет chat completion запросы к конкретному API
+type Provider interface {
+	// Name возвращает название провайдера для логов
+	Name() string
+	// Generate выполняет запрос и возвращает ответ целиком
+	Generate(ctx context.Context, request ChatRequest) (*ChatResponse, error)
+}
+
+// StreamProvider - провайдер, который умеет отдавать ответ по частям
+type StreamProvider interface {
+	Provider
+	// Stream выполняет запрос, вызывая onDelta для каждого нового фрагмента текста,
+	// и возвращает собранный ответ целиком. Ошибка onDelta прерывает генерацию.
+	Stream(ctx context.Context, request ChatRequest, onDelta func(delta string) error) (*ChatResponse, error)
+}
+
+// Generator генерирует ответ бота по контексту чата. Обработчик обновлений зависит от этого
+// интерфейса, а не от конкретного клиента.
+type Generator interface {
+	GenerateResponse(ctx context.Context, req GenerateRequest) (string, error)
+	// ContextWindow возвращает контекстное окно в токенах, в которое должны помещаться запрос к модели
+	// (пустая строка - модель по умолчанию) и ответ на него
+	ContextWindow(model string) int
+}
+
+// StreamGenerator - генератор, который умеет отдавать ответ по мере генерации
+type StreamGenerator interface {
+	Generator
+	// StreamResponse вызывает onDelta для каждого нового фрагмента ответа и возвращает ответ целиком
+	StreamResponse(ctx context.Context, req GenerateRequest, onDelta func(delta string) error) (string, error)
+}
+
+// ProviderConfig описывает выбранного провайдера
+type ProviderConfig struct {
+	Name    string
+	BaseURL string // Пустой - адрес по умолчанию для провайдера
+	APIKey  string
+	// MockScript - ответы мок-провайдера по порядку (пустой - мок повторяет сообщение пользователя)
+	MockScript []string
+}
+
+// NewProvider создает провайдера по конфигурации
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	switch cfg.Name {
+	case ProviderOpenAI:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultOpenAIBaseURL
+		}
+		return NewOpenAIProvider(baseURL, cfg.APIKey), nil
+	case ProviderOllama:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultOllamaBaseURL
+		}
+		return NewOllamaProvider(baseURL), nil
+	case ProviderMock:
+		return NewMockProvider(cfg.MockScript...), nil
+	default:
+		return nil, fmt.Errorf("неизвестный провайдер LLM: %q", cfg.Name)
+	}
+}
+
+// newHTTPClient возвращает HTTP клиент для запросов к API провайдера
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+	}
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIError - ответ API с кодом ошибки
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter - пауза, которую API попросил выдержать перед повтором (заголовок Retry-After), 0 - не указана
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API вернул ошибку %d: %s", e.StatusCode, e.Body)
+}
+
+// newAPIError создает ошибку по HTTP ответу с неуспешным статусом
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: число секунд или HTTP-дата
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable сообщает, что запрос стоит повторить: сбой сети, таймаут, 429 или ошибка сервера.
+// Такие ошибки, кроме 429, говорят о проблемах провайдера и учитываются автоматическим выключателем.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= 500
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// isRateLimited сообщает, что провайдер ограничил частоту запросов (429): он работает,
+// поэтому такая ошибка не считается сбоем для автоматического выключателя
+func isRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// shouldFallback сообщает, есть ли смысл пробовать следующую модель после ошибки.
+// Ошибки авторизации одинаковы для всех моделей провайдера.
+func shouldFallback(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden
+	}
+	return true
+}
+
+// RetryPolicy задает повторы запроса к одной модели
+type RetryPolicy struct {
+	MaxAttempts int           // Всего попыток на модель, включая первую
+	BaseDelay   time.Duration // Пауза перед первым повтором, дальше удваивается
+	MaxDelay    time.Duration // Предельная пауза между попытками
+}
+
+// attempts возвращает число попыток (не меньше одной)
+func (p RetryPolicy) attempts() int {
+	return max(p.MaxAttempts, 1)
+}
+
+// delay возвращает паузу перед повтором после неудачной попытки attempt (с нуля).
+// Пауза растет экспоненциально со случайным разбросом, чтобы повторы разных запросов не совпадали.
+// Если API указал Retry-After, выдерживается именно он; ok = false, если ждать дольше MaxDelay
+// не стоит - тогда лучше сразу перейти к следующей модели.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= p.MaxDelay
+	}
+
+	backoff := p.BaseDelay << min(attempt, 30)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	// Разброс в пределах [backoff/2, backoff]
+	half := backoff / 2
+	return half + rand.N(half+1), true
+}
+
+// sleep ждет d или отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
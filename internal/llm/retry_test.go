@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "0", want: 0},
+		{value: "-3", want: 0},
+		{value: "soon", want: 0},
+		{value: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, ожидалось %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name     string
+		attempt  int
+		err      error
+		min, max time.Duration
+		wantOK   bool
+	}{
+		{name: "первый повтор", attempt: 0, err: &APIError{StatusCode: 503}, min: 50 * time.Millisecond, max: 100 * time.Millisecond, wantOK: true},
+		{name: "пауза удваивается", attempt: 2, err: &APIError{StatusCode: 503}, min: 200 * time.Millisecond, max: 400 * time.Millisecond, wantOK: true},
+		{name: "пауза ограничена", attempt: 10, err: &APIError{StatusCode: 503}, min: 500 * time.Millisecond, max: time.Second, wantOK: true},
+		{name: "Retry-After", attempt: 0, err: &APIError{StatusCode: 429, RetryAfter: 700 * time.Millisecond}, min: 700 * time.Millisecond, max: 700 * time.Millisecond, wantOK: true},
+		{name: "слишком долгий Retry-After", attempt: 0, err: &APIError{StatusCode: 429, RetryAfter: time.Minute}, min: time.Minute, max: time.Minute, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := policy.delay(tt.attempt, tt.err)
+			if ok != tt.wantOK || got < tt.min || got > tt.max {
+				t.Errorf("delay = %s, %t, ожидалось от %s до %s, %t", got, ok, tt.min, tt.max, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantRetry    bool
+		wantFallback bool
+	}{
+		{name: "429", err: &APIError{StatusCode: 429}, wantRetry: true, wantFallback: true},
+		{name: "503", err: &APIError{StatusCode: 503}, wantRetry: true, wantFallback: true},
+		{name: "408", err: &APIError{StatusCode: 408}, wantRetry: true, wantFallback: true},
+		{name: "400", err: &APIError{StatusCode: 400}, wantRetry: false, wantFallback: true},
+		{name: "401", err: &APIError{StatusCode: 401}, wantRetry: false, wantFallback: false},
+		{name: "403", err: &APIError{StatusCode: 403}, wantRetry: false, wantFallback: false},
+		{name: "сбой сети", err: &url.Error{Op: "Post", URL: "http://llm", Err: fmt.Errorf("connection refused")}, wantRetry: true, wantFallback: true},
+		{name: "отмена", err: fmt.Errorf("запрос: %w", context.Canceled), wantRetry: false, wantFallback: false},
+		{name: "выключатель", err: ErrCircuitOpen, wantRetry: false, wantFallback: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.wantRetry {
+				t.Errorf("isRetryable = %t", got)
+			}
+			if got := shouldFallback(tt.err); got != tt.wantFallback {
+				t.Errorf("shouldFallback = %t", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxStopSequences - сколько стоп-последовательностей принимают OpenAI-совместимые API
+const MaxStopSequences = 4
+
+// MaxTokensLimit ограничивает max_tokens, чтобы опечатка в настройках не стоила дорого
+const MaxTokensLimit = 100000
+
+// SamplingParams - параметры генерации LLM. Незаданные (nil) параметры не передаются в API,
+// и провайдер использует собственные значения по умолчанию.
+type SamplingParams struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// Merge возвращает параметры, в которых заданные в override значения заменяют текущие
+func (p SamplingParams) Merge(override SamplingParams) SamplingParams {
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		p.MaxTokens = override.MaxTokens
+	}
+	if len(override.Stop) > 0 {
+		p.Stop = override.Stop
+	}
+	if override.PresencePenalty != nil {
+		p.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		p.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.Seed != nil {
+		p.Seed = override.Seed
+	}
+	return p
+}
+
+// IsZero сообщает, что ни один параметр не задан
+func (p SamplingParams) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == nil && len(p.Stop) == 0 &&
+		p.PresencePenalty == nil && p.FrequencyPenalty == nil && p.Seed == nil
+}
+
+// Validate проверяет, что заданные параметры находятся в допустимых для API пределах
+func (p SamplingParams) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature должна быть от 0 до 2")
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p должен быть больше 0 и не больше 1")
+	}
+	if p.MaxTokens != nil && (*p.MaxTokens < 1 || *p.MaxTokens > MaxTokensLimit) {
+		return fmt.Errorf("max_tokens должен быть от 1 до %d", MaxTokensLimit)
+	}
+	if len(p.Stop) > MaxStopSequences {
+		return fmt.Errorf("stop может содержать не больше %d последовательностей", MaxStopSequences)
+	}
+	if p.PresencePenalty != nil && (*p.PresencePenalty < -2 || *p.PresencePenalty > 2) {
+		return fmt.Errorf("presence_penalty должен быть от -2 до 2")
+	}
+	if p.FrequencyPenalty != nil && (*p.FrequencyPenalty < -2 || *p.FrequencyPenalty > 2) {
+		return fmt.Errorf("frequency_penalty должен быть от -2 до 2")
+	}
+	return nil
+}
+
+// SamplingParamNames - имена параметров генерации, как в OpenAI-совместимом API
+var SamplingParamNames = []string{
+	"temperature", "top_p", "max_tokens", "stop", "presence_penalty", "frequency_penalty", "seed",
+}
+
+// Value возвращает значение параметра name в виде текста (пустая строка - параметр не задан)
+func (p SamplingParams) Value(name string) string {
+	formatFloat := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'g', -1, 64)
+	}
+	formatInt := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+
+	switch name {
+	case "temperature":
+		return formatFloat(p.Temperature)
+	case "top_p":
+		return formatFloat(p.TopP)
+	case "max_tokens":
+		return formatInt(p.MaxTokens)
+	case "stop":
+		if len(p.Stop) == 0 {
+			return ""
+		}
+		return strconv.Quote(strings.Join(p.Stop, "|"))
+	case "presence_penalty":
+		return formatFloat(p.PresencePenalty)
+	case "frequency_penalty":
+		return formatFloat(p.FrequencyPenalty)
+	case "seed":
+		return formatInt(p.Seed)
+	}
+	return ""
+}
+
+// String возвращает заданные параметры в виде "temperature=0.7 max_tokens=500" (пустая строка - ничего не задано)
+func (p SamplingParams) String() string {
+	var parts []string
+	for _, name := range SamplingParamNames {
+		if value := p.Value(name); value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseStopSequences разбирает стоп-последовательности, разделенные "|"; \n обозначает перевод строки
+func ParseStopSequences(value string) []string {
+	var stop []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.ReplaceAll(strings.TrimSpace(item), `\n`, "\n"); item != "" {
+			stop = append(stop, item)
+		}
+	}
+	return stop
+}
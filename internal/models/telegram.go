@@ -15,8 +15,19 @@ type ChatDocument struct {
 	LastName  string    `db:"last_name" json:"last_name"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// MigratedToChatID - новый ID чата после преобразования группы в супергруппу (0, если не было)
+	MigratedToChatID int64 `db:"migrated_to_chat_id" json:"migrated_to_chat_id,omitempty"`
+	IsForum          bool  `db:"is_forum" json:"is_forum"` // Супергруппа с темами
 }
 
+// Происхождение сообщения в истории
+const (
+	// MessageOriginIncoming - сообщение получено от Telegram в обновлении
+	MessageOriginIncoming = "incoming"
+	// MessageOriginOutgoing - сообщение отправлено самим ботом (update_id у него нет)
+	MessageOriginOutgoing = "outgoing"
+)
+
 // MessageDocument представляет запись сообщения в SQLite
 type MessageDocument struct {
 	ID               int64     `db:"id" json:"id"`
@@ -28,8 +39,131 @@ type MessageDocument struct {
 	LastName         string    `db:"last_name" json:"last_name"`
 	Text             string    `db:"text" json:"text"`
 	Date             time.Time `db:"date" json:"date"`
-	UpdateID         int       `db:"update_id" json:"update_id"`
+	UpdateID         int       `db:"update_id" json:"update_id"` // 0 для сообщений без обновления (NULL в базе)
+	Origin           string    `db:"origin" json:"origin"`
 	IsBot            bool      `db:"is_bot" json:"is_bot"`
 	IsAddressedToBot bool      `db:"is_addressed_to_bot" json:"is_addressed_to_bot"`
-	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	EditedAt         time.Time `db:"edit_date" json:"edited_at"` // Нулевое время, если сообщение не редактировалось
+	// SenderChatID - канал или группа, от имени которых отправлено сообщение (0 - отправил пользователь)
+	SenderChatID int64 `db:"sender_chat_id" json:"sender_chat_id,omitempty"`
+	// ForwardFromChatID и ForwardFromMessageID - исходный пост канала для пересланного сообщения
+	ForwardFromChatID    int64 `db:"forward_from_chat_id" json:"forward_from_chat_id,omitempty"`
+	ForwardFromMessageID int   `db:"forward_from_message_id" json:"forward_from_message_id,omitempty"`
+	// IsAutomaticForward - пост канала, автоматически пересланный в привязанную группу обсуждений
+	IsAutomaticForward bool `db:"is_automatic_forward" json:"is_automatic_forward"`
+	// ReplyToMessageID - сообщение, на которое это сообщение отвечает (0 - не ответ)
+	ReplyToMessageID int `db:"reply_to_message_id" json:"reply_to_message_id,omitempty"`
+	// MessageThreadID - ветка ответов или тема форума, к которой относится сообщение
+	MessageThreadID int `db:"message_thread_id" json:"message_thread_id,omitempty"`
+	// TopicID - тема форума (0 - общая тема или чат без тем)
+	TopicID   int       `db:"topic_id" json:"topic_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// MessageEdit представляет правку сообщения в истории правок
+type MessageEdit struct {
+	ID           int64     `db:"id" json:"id"`
+	ChatID       int64     `db:"chat_id" json:"chat_id"`
+	MessageID    int       `db:"message_id" json:"message_id"`
+	UpdateID     int       `db:"update_id" json:"update_id"`
+	PreviousText string    `db:"previous_text" json:"previous_text"`
+	Text         string    `db:"text" json:"text"`
+	EditedAt     time.Time `db:"edited_at" json:"edited_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// ChatSettings представляет индивидуальные настройки чата или отдельной темы форума в SQLite
+type ChatSettings struct {
+	ChatID         int64     `db:"chat_id" json:"chat_id"`
+	TopicID        int       `db:"topic_id" json:"topic_id"` // 0 - настройки всего чата
+	PersonaPrompt  string    `db:"persona_prompt" json:"persona_prompt"`
+	Model          string    `db:"model" json:"model"`
+	ContextTokens  *int      `db:"context_tokens" json:"context_tokens,omitempty"` // Бюджет токенов истории чата
+	AmbientRatio   *float64  `db:"ambient_ratio" json:"ambient_ratio,omitempty"`   // Доля бюджета на фоновый разговор
+	SamplingParams           // Параметры генерации, переопределяющие глобальные
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MergeChatSettings накладывает настройки темы на настройки чата: заданные в теме значения
+// заменяют значения чата. Любой из аргументов может быть nil.
+func MergeChatSettings(chat, topic *ChatSettings) *ChatSettings {
+	if topic == nil {
+		return chat
+	}
+	if chat == nil {
+		return topic
+	}
+
+	merged := *chat
+	merged.TopicID = topic.TopicID
+	if topic.PersonaPrompt != "" {
+		merged.PersonaPrompt = topic.PersonaPrompt
+	}
+	if topic.Model != "" {
+		merged.Model = topic.Model
+	}
+	merged.SamplingParams = chat.SamplingParams.Merge(topic.SamplingParams)
+	if topic.ContextTokens != nil {
+		merged.ContextTokens = topic.ContextTokens
+	}
+	if topic.AmbientRatio != nil {
+		merged.AmbientRatio = topic.AmbientRatio
+	}
+	return &merged
+}
+
+// ChatStats представляет агрегированную статистику сообщений чата
+type ChatStats struct {
+	TotalMessages     int       `json:"total_messages"`
+	BotMessages       int       `json:"bot_messages"`
+	AddressedMessages int       `json:"addressed_messages"`
+	Participants      int       `json:"participants"`
+	FirstMessageAt    time.Time `json:"first_message_at"`
+	LastMessageAt     time.Time `json:"last_message_at"`
+}
+
+// SearchResult - сообщение, найденное полнотекстовым поиском
+type SearchResult struct {
+	Message *MessageDocument `json:"message"`
+	Snippet string           `json:"snippet"` // Фрагмент текста с выделенными совпадениями
+}
+
+// UserDocument представляет пользователя Telegram с его текущим именем
+type UserDocument struct {
+	UserID      int64     `db:"user_id" json:"user_id"`
+	Username    string    `db:"username" json:"username"`
+	FirstName   string    `db:"first_name" json:"first_name"`
+	LastName    string    `db:"last_name" json:"last_name"`
+	IsBot       bool      `db:"is_bot" json:"is_bot"`
+	FirstSeenAt time.Time `db:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  time.Time `db:"last_seen_at" json:"last_seen_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// UserNameChange - вариант имени пользователя и момент, когда он был замечен впервые
+type UserNameChange struct {
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Username  string    `db:"username" json:"username"`
+	FirstName string    `db:"first_name" json:"first_name"`
+	LastName  string    `db:"last_name" json:"last_name"`
+	SeenAt    time.Time `db:"seen_at" json:"seen_at"`
+}
+
+// ChatMember - пользователь, писавший в чат
+type ChatMember struct {
+	ChatID      int64         `db:"chat_id" json:"chat_id"`
+	User        *UserDocument `json:"user"`
+	FirstSeenAt time.Time     `db:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  time.Time     `db:"last_seen_at" json:"last_seen_at"`
+}
+
+// UserStats представляет статистику сообщений пользователя
+type UserStats struct {
+	UserID            int64     `json:"user_id"`
+	ChatID            int64     `json:"chat_id"` // 0 - по всем чатам
+	TotalMessages     int       `json:"total_messages"`
+	AddressedMessages int       `json:"addressed_messages"`
+	FirstMessageAt    time.Time `json:"first_message_at"`
+	LastMessageAt     time.Time `json:"last_message_at"`
 }
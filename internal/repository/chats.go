@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// chatLineageCTE - рекурсивный CTE lineage(chat_id, depth): ID чата (первый параметр, depth = 0)
+// и всех групп, которые были в него преобразованы (depth - сколько преобразований назад).
+// Данные группы остаются под ее старым ID: номера сообщений в группе и супергруппе независимы
+// и пересекаются, поэтому история связывается через chats.migrated_to_chat_id, а все чтения данных
+// чата - сообщений, настроек, слов-триггеров, сбросов контекста и участников - идут по всей цепочке.
+// Исключение - поиск отдельного сообщения по номеру: номер сообщения имеет смысл только в своем чате.
+const chatLineageCTE = `
+	WITH RECURSIVE lineage(chat_id, depth) AS (
+		SELECT ?, 0
+		UNION
+		SELECT chats.chat_id, lineage.depth + 1
+		FROM chats JOIN lineage ON chats.migrated_to_chat_id = lineage.chat_id
+		WHERE lineage.depth < 10
+	)`
+
+// chatLineage - подзапрос с ID чата (первый параметр) и всех групп, которые были в него преобразованы
+const chatLineage = chatLineageCTE + `
+	SELECT chat_id FROM lineage`
+
+// MigrateChat связывает группу с супергруппой, в которую она преобразована: старая запись чата получает
+// ссылку на новую, и история, настройки, слова-триггеры, сбросы контекста и участники группы
+// читаются для супергруппы через chatLineage. Сами строки не переносятся. Повторный вызов безопасен.
+func (r *SQLiteRepository) MigrateChat(ctx context.Context, oldChatID, newChatID int64) error {
+	if oldChatID == newChatID {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE chats SET migrated_to_chat_id = ?, updated_at = ? WHERE chat_id = ?",
+		newChatID, time.Now(), oldChatID)
+	return err
+}
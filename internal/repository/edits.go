@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// EditMessage применяет правку к сохраненному сообщению и записывает ее в историю правок.
+// Обновления могут прийти не по порядку: текст сообщения меняется, только если правка не старше
+// уже примененной, а опоздавшая правка встает в историю на свое место по времени - ее прежний текст
+// берется из предыдущей правки, а следующая правка теперь начинается с ее текста.
+// Правки, не меняющие текст, не записываются. Возвращает false, если исходного сообщения нет в базе.
+func (r *SQLiteRepository) EditMessage(ctx context.Context, edit *models.MessageEdit) (bool, error) {
+	found := false
+	err := r.inTransaction(ctx, func(tx *sql.Tx) error {
+		var currentText sql.NullString
+		var editDate sql.NullTime
+		err := tx.QueryRowContext(ctx,
+			"SELECT text, edit_date FROM messages WHERE chat_id = ? AND message_id = ?",
+			edit.ChatID, edit.MessageID,
+		).Scan(&currentText, &editDate)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		before, after, err := neighbourEdits(ctx, tx, edit)
+		if err != nil {
+			return err
+		}
+		// Текст до правки - текст предыдущей правки, а если правка самая ранняя - исходный текст сообщения
+		previousText := currentText.String
+		switch {
+		case before != nil:
+			previousText = before.Text
+		case after != nil:
+			previousText = after.PreviousText
+		}
+		// Telegram присылает правку и когда меняется только медиа или разметка - текст при этом тот же
+		if previousText == edit.Text {
+			return nil
+		}
+
+		result, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO message_edits (
+			chat_id, message_id, update_id, previous_text, text, edited_at, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			edit.ChatID, edit.MessageID, nullableInt(edit.UpdateID), previousText,
+			edit.Text, edit.EditedAt, time.Now())
+		if err != nil {
+			return err
+		}
+		// Это обновление уже было применено
+		if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+			return err
+		}
+
+		// Опоздавшая правка: следующая за ней правка меняла уже ее текст
+		if after != nil {
+			_, err := tx.ExecContext(ctx,
+				"UPDATE message_edits SET previous_text = ? WHERE id = ?", edit.Text, after.ID)
+			return err
+		}
+		if editDate.Valid && edit.EditedAt.Before(editDate.Time) {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE messages SET text = ?, edit_date = ? WHERE chat_id = ? AND message_id = ?",
+			edit.Text, edit.EditedAt, edit.ChatID, edit.MessageID)
+		return err
+	})
+	return found, err
+}
+
+// neighbourEdits находит в истории правок сообщения последнюю правку не позже edit
+// и первую правку после нее (nil, если таких нет)
+func neighbourEdits(
+	ctx context.Context,
+	tx *sql.Tx,
+	edit *models.MessageEdit,
+) (before, after *models.MessageEdit, err error) {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, previous_text, text, edited_at
+	FROM message_edits
+	WHERE chat_id = ? AND message_id = ?
+	ORDER BY id ASC`,
+		edit.ChatID, edit.MessageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &models.MessageEdit{}
+		var previousText, text sql.NullString
+		if err := rows.Scan(&e.ID, &previousText, &text, &e.EditedAt); err != nil {
+			return nil, nil, err
+		}
+		e.PreviousText = previousText.String
+		e.Text = text.String
+
+		if !e.EditedAt.After(edit.EditedAt) {
+			if before == nil || !e.EditedAt.Before(before.EditedAt) {
+				before = e
+			}
+		} else if after == nil || e.EditedAt.Before(after.EditedAt) {
+			after = e
+		}
+	}
+	return before, after, rows.Err()
+}
+
+// GetMessageEdits возвращает историю правок сообщения в хронологическом порядке
+func (r *SQLiteRepository) GetMessageEdits(
+	ctx context.Context,
+	chatID int64,
+	messageID int,
+) ([]*models.MessageEdit, error) {
+	query := `
+	SELECT id, chat_id, message_id, update_id, previous_text, text, edited_at, created_at
+	FROM message_edits
+	WHERE chat_id = ? AND message_id = ?
+	ORDER BY edited_at ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edits []*models.MessageEdit
+	for rows.Next() {
+		edit := &models.MessageEdit{}
+		var updateID sql.NullInt64
+		var previousText, text sql.NullString
+		err := rows.Scan(&edit.ID, &edit.ChatID, &edit.MessageID, &updateID,
+			&previousText, &text, &edit.EditedAt, &edit.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		edit.UpdateID = int(updateID.Int64)
+		edit.PreviousText = previousText.String
+		edit.Text = text.String
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
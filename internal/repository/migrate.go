@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles содержит SQL-миграции вида NNNN_name.up.sql и NNNN_name.down.sql.
+// Номер миграции задает порядок применения; уже выпущенные файлы не меняются,
+// любое изменение схемы оформляется новой миграцией.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDatabaseNewer возвращается, если в базе применены миграции, о которых этот бинарник не знает
+var ErrDatabaseNewer = errors.New("схема базы данных новее, чем поддерживает эта версия бота")
+
+// Migration - одна версия схемы базы данных
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus описывает состояние миграции в конкретной базе
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// loadMigrations читает встроенные миграции, упорядоченные по номеру версии
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("некорректное имя файла миграции: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения миграции %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("у миграции %d разные имена: %s и %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s должны быть файлы up и down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationsTable создает таблицу учета примененных миграций
+func (r *SQLiteRepository) ensureMigrationsTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations возвращает время применения каждой примененной миграции
+func (r *SQLiteRepository) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if err := r.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// SchemaVersion возвращает номер последней примененной миграции (0 для пустой базы)
+func (r *SQLiteRepository) SchemaVersion(ctx context.Context) (int, error) {
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// checkSchemaVersion возвращает ErrDatabaseNewer, если база была мигрирована более новой версией бота
+func (r *SQLiteRepository) checkSchemaVersion(ctx context.Context, migrations []Migration) error {
+	current, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return fmt.Errorf("%w: версия базы %d, последняя известная миграция %d", ErrDatabaseNewer, current, latest)
+	}
+	return nil
+}
+
+// MigrationStatus возвращает список всех известных миграций с отметкой о применении
+func (r *SQLiteRepository) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// MigrateUp применяет все еще не примененные миграции. Каждая миграция выполняется
+// в собственной транзакции вместе с записью в schema_migrations.
+// Возвращает количество примененных миграций.
+func (r *SQLiteRepository) MigrateUp(ctx context.Context) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := r.checkSchemaVersion(ctx, migrations); err != nil {
+		return 0, err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err := r.inTransaction(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now())
+			return err
+		})
+		if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+			return count, fmt.Errorf("ошибка применения миграции %04d_%s: %w (бинарник нужно собирать с -tags sqlite_fts5)",
+				m.Version, m.Name, err)
+		}
+		if err != nil {
+			return count, fmt.Errorf("ошибка применения миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Применена миграция %04d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown откатывает последние steps примененных миграций.
+// Возвращает количество откаченных миграций.
+func (r *SQLiteRepository) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := r.checkSchemaVersion(ctx, migrations); err != nil {
+		return 0, err
+	}
+	applied, err := r.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := r.inTransaction(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("ошибка отката миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Откачена миграция %04d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// inTransaction выполняет fn в транзакции и фиксирует ее, если fn не вернула ошибку
+func (r *SQLiteRepository) inTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
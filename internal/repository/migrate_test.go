@@ -0,0 +1,22 @@
+package repository
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("нет встроенных миграций")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("миграция %04d_%s на месте %d: номера должны идти подряд с 1", m.Version, m.Name, i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("у миграции %04d_%s нет up или down", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+-- Исходная схема: чаты и сообщения.
+-- IF NOT EXISTS позволяет применить миграцию к базам, созданным до появления schema_migrations.
+CREATE TABLE IF NOT EXISTS chats (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id INTEGER UNIQUE NOT NULL,
+	type TEXT NOT NULL,
+	title TEXT,
+	username TEXT,
+	first_name TEXT,
+	last_name TEXT,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id INTEGER NOT NULL,
+	chat_id INTEGER NOT NULL,
+	user_id INTEGER,
+	username TEXT,
+	first_name TEXT,
+	last_name TEXT,
+	text TEXT,
+	date DATETIME NOT NULL,
+	update_id INTEGER UNIQUE NOT NULL,
+	is_bot BOOLEAN NOT NULL DEFAULT 0,
+	is_addressed_to_bot BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	UNIQUE(message_id, chat_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chats_chat_id ON chats(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_update_id ON messages(update_id);
+CREATE INDEX IF NOT EXISTS idx_messages_date ON messages(date);
+CREATE INDEX IF NOT EXISTS idx_messages_text ON messages(text);
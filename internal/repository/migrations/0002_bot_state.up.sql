@@ -0,0 +1,6 @@
+-- Служебное состояние бота (например, offset long polling)
+CREATE TABLE IF NOT EXISTS bot_state (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
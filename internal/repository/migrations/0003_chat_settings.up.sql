@@ -0,0 +1,9 @@
+-- Индивидуальные настройки чатов: персона, модель, температура
+CREATE TABLE IF NOT EXISTS chat_settings (
+	chat_id INTEGER PRIMARY KEY,
+	persona_prompt TEXT NOT NULL DEFAULT '',
+	model TEXT NOT NULL DEFAULT '',
+	temperature REAL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
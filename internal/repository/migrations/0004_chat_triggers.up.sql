@@ -0,0 +1,7 @@
+-- Дополнительные слова-триггеры чатов
+CREATE TABLE IF NOT EXISTS chat_triggers (
+	chat_id INTEGER NOT NULL,
+	word TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (chat_id, word)
+);
@@ -0,0 +1,5 @@
+-- С какого момента начинается текущий разговор в чате (/reset)
+CREATE TABLE IF NOT EXISTS context_resets (
+	chat_id INTEGER PRIMARY KEY,
+	reset_at DATETIME NOT NULL
+);
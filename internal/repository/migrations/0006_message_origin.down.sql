@@ -0,0 +1,35 @@
+-- Возврат к обязательному update_id. Сообщениям бота нужен уникальный update_id,
+-- поэтому вместо 0 им присваивается -id (отрицательные значения Telegram не использует).
+CREATE TABLE messages_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id INTEGER NOT NULL,
+	chat_id INTEGER NOT NULL,
+	user_id INTEGER,
+	username TEXT,
+	first_name TEXT,
+	last_name TEXT,
+	text TEXT,
+	date DATETIME NOT NULL,
+	update_id INTEGER UNIQUE NOT NULL,
+	is_bot BOOLEAN NOT NULL DEFAULT 0,
+	is_addressed_to_bot BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	UNIQUE(message_id, chat_id)
+);
+
+INSERT INTO messages_old (
+	id, message_id, chat_id, user_id, username, first_name, last_name,
+	text, date, update_id, is_bot, is_addressed_to_bot, created_at
+)
+SELECT
+	id, message_id, chat_id, user_id, username, first_name, last_name,
+	text, date, COALESCE(update_id, -id), is_bot, is_addressed_to_bot, created_at
+FROM messages;
+
+DROP TABLE messages;
+ALTER TABLE messages_old RENAME TO messages;
+
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_update_id ON messages(update_id);
+CREATE INDEX IF NOT EXISTS idx_messages_date ON messages(date);
+CREATE INDEX IF NOT EXISTS idx_messages_text ON messages(text);
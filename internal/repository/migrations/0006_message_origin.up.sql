@@ -0,0 +1,40 @@
+-- Сообщения бота раньше сохранялись с update_id = 0, и из-за UNIQUE сохранялось только первое.
+-- update_id становится nullable, а происхождение сообщения хранится в колонке origin.
+-- SQLite не умеет менять ограничения колонок, поэтому таблица пересоздается.
+-- Выражения ниже работают и для старой схемы, и для баз, где колонка origin уже появилась.
+CREATE TABLE messages_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id INTEGER NOT NULL,
+	chat_id INTEGER NOT NULL,
+	user_id INTEGER,
+	username TEXT,
+	first_name TEXT,
+	last_name TEXT,
+	text TEXT,
+	date DATETIME NOT NULL,
+	update_id INTEGER UNIQUE,
+	origin TEXT NOT NULL DEFAULT 'incoming',
+	is_bot BOOLEAN NOT NULL DEFAULT 0,
+	is_addressed_to_bot BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	UNIQUE(message_id, chat_id)
+);
+
+INSERT INTO messages_new (
+	id, message_id, chat_id, user_id, username, first_name, last_name,
+	text, date, update_id, origin, is_bot, is_addressed_to_bot, created_at
+)
+SELECT
+	id, message_id, chat_id, user_id, username, first_name, last_name,
+	text, date, NULLIF(update_id, 0),
+	CASE WHEN update_id IS NULL OR update_id = 0 THEN 'outgoing' ELSE 'incoming' END,
+	is_bot, is_addressed_to_bot, created_at
+FROM messages;
+
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_update_id ON messages(update_id);
+CREATE INDEX IF NOT EXISTS idx_messages_date ON messages(date);
+CREATE INDEX IF NOT EXISTS idx_messages_text ON messages(text);
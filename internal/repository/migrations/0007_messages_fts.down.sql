@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS messages_fts_update;
+DROP TRIGGER IF EXISTS messages_fts_delete;
+DROP TRIGGER IF EXISTS messages_fts_insert;
+DROP TABLE IF EXISTS messages_fts;
+
+CREATE INDEX IF NOT EXISTS idx_messages_text ON messages(text);
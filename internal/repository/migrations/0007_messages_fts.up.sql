@@ -0,0 +1,34 @@
+-- Полнотекстовый поиск по сообщениям (FTS5; бинарник должен собираться с тегом sqlite_fts5).
+-- B-tree индекс по тексту не помогает искать внутри сообщений и только замедляет вставку.
+DROP INDEX IF EXISTS idx_messages_text;
+
+-- Индекс хранит только токены, сам текст берется из messages (external content).
+-- unicode61 не отождествляет "ё" и "е", поэтому в индекс попадает текст с заменой ё → е;
+-- в триггере удаления используется то же выражение, иначе индекс рассинхронизируется.
+CREATE VIRTUAL TABLE messages_fts USING fts5(
+	text,
+	chat_id UNINDEXED,
+	content = 'messages',
+	content_rowid = 'id',
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO messages_fts (rowid, text, chat_id)
+SELECT id, replace(replace(text, 'ё', 'е'), 'Ё', 'Е'), chat_id FROM messages;
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts (rowid, text, chat_id)
+	VALUES (new.id, replace(replace(new.text, 'ё', 'е'), 'Ё', 'Е'), new.chat_id);
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, text, chat_id)
+	VALUES ('delete', old.id, replace(replace(old.text, 'ё', 'е'), 'Ё', 'Е'), old.chat_id);
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF text, chat_id ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, text, chat_id)
+	VALUES ('delete', old.id, replace(replace(old.text, 'ё', 'е'), 'Ё', 'Е'), old.chat_id);
+	INSERT INTO messages_fts (rowid, text, chat_id)
+	VALUES (new.id, replace(replace(new.text, 'ё', 'е'), 'Ё', 'Е'), new.chat_id);
+END;
@@ -0,0 +1,17 @@
+-- Время последнего редактирования сообщения (NULL, если сообщение не редактировалось)
+ALTER TABLE messages ADD COLUMN edit_date DATETIME;
+
+-- История правок: каждая строка - одна правка с текстом до и после нее.
+-- update_id уникален, чтобы повторная доставка того же обновления не дублировала историю.
+CREATE TABLE message_edits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id INTEGER NOT NULL,
+	message_id INTEGER NOT NULL,
+	update_id INTEGER UNIQUE,
+	previous_text TEXT,
+	text TEXT,
+	edited_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_message_edits_message ON message_edits(chat_id, message_id);
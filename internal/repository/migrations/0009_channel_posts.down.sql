@@ -0,0 +1,4 @@
+ALTER TABLE messages DROP COLUMN is_automatic_forward;
+ALTER TABLE messages DROP COLUMN forward_from_message_id;
+ALTER TABLE messages DROP COLUMN forward_from_chat_id;
+ALTER TABLE messages DROP COLUMN sender_chat_id;
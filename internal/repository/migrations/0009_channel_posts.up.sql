@@ -0,0 +1,7 @@
+-- Посты каналов и автоматические пересылки постов в группу обсуждений.
+-- sender_chat_id - чат, от имени которого отправлено сообщение (канал или анонимный админ группы);
+-- forward_from_* - исходный пост канала для пересланных сообщений.
+ALTER TABLE messages ADD COLUMN sender_chat_id INTEGER;
+ALTER TABLE messages ADD COLUMN forward_from_chat_id INTEGER;
+ALTER TABLE messages ADD COLUMN forward_from_message_id INTEGER;
+ALTER TABLE messages ADD COLUMN is_automatic_forward BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE chats DROP COLUMN migrated_to_chat_id;
@@ -0,0 +1,2 @@
+-- Группа, преобразованная в супергруппу, получает новый ID; старая запись чата ссылается на новую
+ALTER TABLE chats ADD COLUMN migrated_to_chat_id INTEGER;
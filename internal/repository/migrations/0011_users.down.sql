@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS chat_members;
+DROP TABLE IF EXISTS user_name_history;
+DROP TABLE IF EXISTS users;
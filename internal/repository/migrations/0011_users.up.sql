@@ -0,0 +1,57 @@
+-- Пользователи с текущими именами, история смены имен и участники чатов.
+-- Данные заполняются из уже сохраненных сообщений; служебный user_id = 0 (сообщения от имени чатов) пропускается.
+CREATE TABLE users (
+	user_id INTEGER PRIMARY KEY,
+	username TEXT NOT NULL DEFAULT '',
+	first_name TEXT NOT NULL DEFAULT '',
+	last_name TEXT NOT NULL DEFAULT '',
+	is_bot BOOLEAN NOT NULL DEFAULT 0,
+	first_seen_at DATETIME NOT NULL,
+	last_seen_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+-- Каждая строка - вариант имени пользователя и момент, когда он был замечен впервые
+CREATE TABLE user_name_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	username TEXT NOT NULL DEFAULT '',
+	first_name TEXT NOT NULL DEFAULT '',
+	last_name TEXT NOT NULL DEFAULT '',
+	seen_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_name_history_user ON user_name_history(user_id, seen_at);
+
+CREATE TABLE chat_members (
+	chat_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	first_seen_at DATETIME NOT NULL,
+	last_seen_at DATETIME NOT NULL,
+	PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX idx_chat_members_user ON chat_members(user_id);
+
+INSERT INTO users (user_id, username, first_name, last_name, is_bot, first_seen_at, last_seen_at, updated_at)
+SELECT m.user_id, COALESCE(m.username, ''), COALESCE(m.first_name, ''), COALESCE(m.last_name, ''),
+	   m.is_bot, s.first_seen, s.last_seen, s.last_seen
+FROM (
+	SELECT user_id, MIN(date) AS first_seen, MAX(date) AS last_seen, MAX(id) AS last_id
+	FROM messages
+	WHERE user_id IS NOT NULL AND user_id != 0
+	GROUP BY user_id
+) s
+JOIN messages m ON m.id = s.last_id;
+
+INSERT INTO user_name_history (user_id, username, first_name, last_name, seen_at)
+SELECT user_id, COALESCE(username, ''), COALESCE(first_name, ''), COALESCE(last_name, ''), MIN(date)
+FROM messages
+WHERE user_id IS NOT NULL AND user_id != 0
+GROUP BY user_id, COALESCE(username, ''), COALESCE(first_name, ''), COALESCE(last_name, '');
+
+INSERT INTO chat_members (chat_id, user_id, first_seen_at, last_seen_at)
+SELECT chat_id, user_id, MIN(date), MAX(date)
+FROM messages
+WHERE user_id IS NOT NULL AND user_id != 0
+GROUP BY chat_id, user_id;
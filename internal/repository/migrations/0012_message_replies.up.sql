@@ -0,0 +1,5 @@
+-- Связи сообщений: на какое сообщение это ответ и к какой ветке (или теме форума) оно относится
+ALTER TABLE messages ADD COLUMN reply_to_message_id INTEGER;
+ALTER TABLE messages ADD COLUMN message_thread_id INTEGER;
+
+CREATE INDEX idx_messages_thread ON messages(chat_id, message_thread_id);
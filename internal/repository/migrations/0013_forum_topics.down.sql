@@ -0,0 +1,20 @@
+-- Настройки отдельных тем теряются: в старой схеме их негде хранить
+CREATE TABLE chat_settings_old (
+	chat_id INTEGER PRIMARY KEY,
+	persona_prompt TEXT NOT NULL DEFAULT '',
+	model TEXT NOT NULL DEFAULT '',
+	temperature REAL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+INSERT INTO chat_settings_old (chat_id, persona_prompt, model, temperature, created_at, updated_at)
+SELECT chat_id, persona_prompt, model, temperature, created_at, updated_at FROM chat_settings WHERE topic_id = 0;
+
+DROP TABLE chat_settings;
+ALTER TABLE chat_settings_old RENAME TO chat_settings;
+
+ALTER TABLE chats DROP COLUMN is_forum;
+
+DROP INDEX IF EXISTS idx_messages_topic;
+ALTER TABLE messages DROP COLUMN topic_id;
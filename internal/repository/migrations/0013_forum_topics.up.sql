@@ -0,0 +1,26 @@
+-- Темы форумов: topic_id - ID темы для сообщений из тем форума, 0 - общая тема или обычный чат.
+-- message_thread_id для этого не подходит: в обычных супергруппах это ветка ответов, а не тема.
+-- Для сохраненных ранее сообщений принадлежность к теме неизвестна, они остаются в общей теме.
+ALTER TABLE messages ADD COLUMN topic_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX idx_messages_topic ON messages(chat_id, topic_id, date);
+
+ALTER TABLE chats ADD COLUMN is_forum BOOLEAN NOT NULL DEFAULT 0;
+
+-- Настройки могут задаваться для отдельной темы; topic_id = 0 - настройки всего чата.
+-- Первичный ключ меняется, поэтому таблица пересоздается.
+CREATE TABLE chat_settings_new (
+	chat_id INTEGER NOT NULL,
+	topic_id INTEGER NOT NULL DEFAULT 0,
+	persona_prompt TEXT NOT NULL DEFAULT '',
+	model TEXT NOT NULL DEFAULT '',
+	temperature REAL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	PRIMARY KEY (chat_id, topic_id)
+);
+
+INSERT INTO chat_settings_new (chat_id, topic_id, persona_prompt, model, temperature, created_at, updated_at)
+SELECT chat_id, 0, persona_prompt, model, temperature, created_at, updated_at FROM chat_settings;
+
+DROP TABLE chat_settings;
+ALTER TABLE chat_settings_new RENAME TO chat_settings;
@@ -0,0 +1,2 @@
+ALTER TABLE chat_settings DROP COLUMN ambient_ratio;
+ALTER TABLE chat_settings DROP COLUMN context_tokens;
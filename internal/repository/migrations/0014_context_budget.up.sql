@@ -0,0 +1,4 @@
+-- Бюджет контекста LLM для чата или темы: примерное число токенов истории и доля фонового разговора
+-- (сообщений, не адресованных боту). NULL - использовать значения из конфигурации.
+ALTER TABLE chat_settings ADD COLUMN context_tokens INTEGER;
+ALTER TABLE chat_settings ADD COLUMN ambient_ratio REAL;
@@ -0,0 +1,6 @@
+ALTER TABLE chat_settings DROP COLUMN seed;
+ALTER TABLE chat_settings DROP COLUMN frequency_penalty;
+ALTER TABLE chat_settings DROP COLUMN presence_penalty;
+ALTER TABLE chat_settings DROP COLUMN stop_sequences;
+ALTER TABLE chat_settings DROP COLUMN max_tokens;
+ALTER TABLE chat_settings DROP COLUMN top_p;
@@ -0,0 +1,8 @@
+-- Параметры генерации LLM для чата или темы. NULL - использовать значения из конфигурации.
+-- stop_sequences хранит JSON-массив строк.
+ALTER TABLE chat_settings ADD COLUMN top_p REAL;
+ALTER TABLE chat_settings ADD COLUMN max_tokens INTEGER;
+ALTER TABLE chat_settings ADD COLUMN stop_sequences TEXT;
+ALTER TABLE chat_settings ADD COLUMN presence_penalty REAL;
+ALTER TABLE chat_settings ADD COLUMN frequency_penalty REAL;
+ALTER TABLE chat_settings ADD COLUMN seed INTEGER;
@@ -0,0 +1,11 @@
+-- Сбросы в отдельных темах теряются: в старой схеме их негде хранить
+CREATE TABLE context_resets_old (
+	chat_id INTEGER PRIMARY KEY,
+	reset_at DATETIME NOT NULL
+);
+
+INSERT INTO context_resets_old (chat_id, reset_at)
+SELECT chat_id, reset_at FROM context_resets WHERE topic_id = 0;
+
+DROP TABLE context_resets;
+ALTER TABLE context_resets_old RENAME TO context_resets;
@@ -0,0 +1,14 @@
+-- /reset в теме форума сбрасывает контекст только этой темы; topic_id = 0 - общая тема или обычный чат.
+-- Первичный ключ меняется, поэтому таблица пересоздается. Прежние сбросы относятся к общей теме.
+CREATE TABLE context_resets_new (
+	chat_id INTEGER NOT NULL,
+	topic_id INTEGER NOT NULL DEFAULT 0,
+	reset_at DATETIME NOT NULL,
+	PRIMARY KEY (chat_id, topic_id)
+);
+
+INSERT INTO context_resets_new (chat_id, topic_id, reset_at)
+SELECT chat_id, 0, reset_at FROM context_resets;
+
+DROP TABLE context_resets;
+ALTER TABLE context_resets_new RENAME TO context_resets;
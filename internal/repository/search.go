@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// Маркеры, которыми в сниппетах выделяются найденные слова
+const (
+	snippetOpen     = "«"
+	snippetClose    = "»"
+	snippetEllipsis = "…"
+	snippetTokens   = 16
+)
+
+// russianEndings - окончания, которые отбрасываются от слов запроса, чтобы находить другие формы слова
+// ("жорика" → "жорик*"). Отсортированы по убыванию длины: отбрасывается самое длинное подходящее.
+var russianEndings = []string{
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ией",
+	"ой", "ей", "ый", "ий", "ая", "яя", "ое", "ее", "ые", "ие", "ую", "юю",
+	"ом", "ем", "ам", "ям", "ах", "ях", "ов", "ев", "ию", "ия",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// minStemLength - минимальная длина основы после отбрасывания окончания
+const minStemLength = 3
+
+// SearchMessages ищет сообщения чата по словам запроса (все слова должны встретиться в сообщении).
+// Слова ищутся по основе, поэтому "жорика" находит "Жорик" и "Жоре"; "ё" и "е" не различаются.
+// Команды боту в результаты не попадают. Результаты упорядочены по релевантности.
+func (r *SQLiteRepository) SearchMessages(
+	ctx context.Context,
+	chatID int64,
+	query string,
+	limit int,
+) ([]*models.SearchResult, error) {
+	match := buildMatchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	sqlQuery := `
+	WITH hits AS (
+		SELECT rowid AS message_rowid,
+			   snippet(messages_fts, 0, ?, ?, ?, ?) AS snippet,
+			   rank
+		FROM messages_fts
+		WHERE messages_fts MATCH ? AND chat_id IN (` + chatLineage + `)
+	)
+	SELECT ` + messageColumns + `, hits.snippet
+	FROM messages
+	JOIN hits ON hits.message_rowid = messages.id
+	WHERE text NOT LIKE '/%'
+	ORDER BY hits.rank, date DESC
+	LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery,
+		snippetOpen, snippetClose, snippetEllipsis, snippetTokens, match, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.SearchResult
+	for rows.Next() {
+		result := &models.SearchResult{}
+		msg, err := scanMessage(withExtraColumns(rows, &result.Snippet))
+		if err != nil {
+			return nil, err
+		}
+		result.Message = msg
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// buildMatchQuery превращает пользовательский запрос в выражение FTS5 MATCH.
+// Каждое слово берется в кавычки (чтобы операторы FTS5 в запросе не ломали синтаксис)
+// и ищется как префикс своей основы.
+func buildMatchQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+		terms = append(terms, `"`+stem(word)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// stem отбрасывает от русского слова окончание, оставляя основу не короче minStemLength
+func stem(word string) string {
+	if !isCyrillic(word) {
+		return word
+	}
+	length := utf8.RuneCountInString(word)
+	for _, ending := range russianEndings {
+		if strings.HasSuffix(word, ending) && length-utf8.RuneCountInString(ending) >= minStemLength {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if !unicode.Is(unicode.Cyrillic, r) {
+			return false
+		}
+	}
+	return true
+}
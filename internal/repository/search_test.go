@@ -0,0 +1,27 @@
+package repository
+
+import "testing"
+
+func TestBuildMatchQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{query: "жорика", want: `"жорик"*`},
+		{query: "Жорик", want: `"жорик"*`},
+		{query: "Ёжиками", want: `"ежик"*`},
+		{query: "новыми словами", want: `"нов"* "слов"*`},
+		{query: "кот", want: `"кот"*`},
+		{query: "моя", want: `"моя"*`},
+		{query: "hello world", want: `"hello"* "world"*`},
+		{query: `жорик" OR NEAR(`, want: `"жорик"* "or"* "near"*`},
+		{query: "  !!! ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := buildMatchQuery(tt.query); got != tt.want {
+				t.Errorf("buildMatchQuery(%q) = %s, ожидалось %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,20 +3,44 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/semyon-ancherbak/sueta/internal/models"
 )
 
 type Repository interface {
 	SaveChat(ctx context.Context, chat *models.ChatDocument) error
+	MigrateChat(ctx context.Context, oldChatID, newChatID int64) error
+	SaveUser(ctx context.Context, user *models.UserDocument, chatID int64, seenAt time.Time) error
+	GetUser(ctx context.Context, userID int64) (*models.UserDocument, error)
+	GetUserNameHistory(ctx context.Context, userID int64) ([]*models.UserNameChange, error)
+	ListChatMembers(ctx context.Context, chatID int64) ([]*models.ChatMember, error)
+	GetUserStats(ctx context.Context, chatID, userID int64) (*models.UserStats, error)
 	SaveMessage(ctx context.Context, message *models.MessageDocument) error
-	ChatExists(ctx context.Context, chatID int64) (bool, error)
+	EditMessage(ctx context.Context, edit *models.MessageEdit) (bool, error)
+	GetMessageEdits(ctx context.Context, chatID int64, messageID int) ([]*models.MessageEdit, error)
 	UpdateExists(ctx context.Context, updateID int) (bool, error)
 	GetRecentMessages(ctx context.Context, chatID int64, days int) ([]*models.MessageDocument, error)
 	GetLastMessages(ctx context.Context, chatID int64, limit int) ([]*models.MessageDocument, error)
+	GetLastTopicMessages(ctx context.Context, chatID int64, topicID int, limit int) ([]*models.MessageDocument, error)
+	GetMessage(ctx context.Context, chatID int64, messageID int) (*models.MessageDocument, error)
+	GetReplyChain(ctx context.Context, chatID int64, messageID int, limit int) ([]*models.MessageDocument, error)
+	GetUpdateOffset(ctx context.Context) (int, error)
+	SaveUpdateOffset(ctx context.Context, offset int) error
+	GetChatSettings(ctx context.Context, chatID int64, topicID int) (*models.ChatSettings, error)
+	SaveChatSettings(ctx context.Context, settings *models.ChatSettings) error
+	DeleteChatSettings(ctx context.Context, chatID int64, topicID int) error
+	ResetContext(ctx context.Context, chatID int64, topicID int, at time.Time) error
+	GetContextResetTime(ctx context.Context, chatID int64, topicID int) (time.Time, error)
+	GetChatStats(ctx context.Context, chatID int64) (*models.ChatStats, error)
+	GetChatTriggers(ctx context.Context, chatID int64) ([]string, error)
+	AddChatTrigger(ctx context.Context, chatID int64, word string) error
+	DeleteChatTrigger(ctx context.Context, chatID int64, word string) (bool, error)
+	SearchMessages(ctx context.Context, chatID int64, query string, limit int) ([]*models.SearchResult, error)
 	Close(ctx context.Context) error
 }
 
@@ -25,100 +49,65 @@ type SQLiteRepository struct {
 	db *sql.DB
 }
 
+// NewRepository открывает базу данных и применяет недостающие миграции схемы.
+// Если база была мигрирована более новой версией бота, возвращается ErrDatabaseNewer.
 func NewRepository(dbPath string) (*SQLiteRepository, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	repo, err := Open(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка открытия базы данных: %w", err)
-	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ошибка подключения к базе данных: %w", err)
+		return nil, err
 	}
 
-	repo := &SQLiteRepository{db: db}
-
-	if err := repo.createTables(); err != nil {
-		return nil, fmt.Errorf("ошибка создания таблиц: %w", err)
+	if _, err := repo.MigrateUp(context.Background()); err != nil {
+		repo.db.Close()
+		return nil, fmt.Errorf("ошибка миграции базы данных: %w", err)
 	}
 
 	return repo, nil
 }
 
-// createTables создает необходимые таблицы
-func (r *SQLiteRepository) createTables() error {
-	// Создаем таблицу chats
-	chatTableSQL := `
-	CREATE TABLE IF NOT EXISTS chats (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		chat_id INTEGER UNIQUE NOT NULL,
-		type TEXT NOT NULL,
-		title TEXT,
-		username TEXT,
-		first_name TEXT,
-		last_name TEXT,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL
-	);`
-
-	if _, err := r.db.Exec(chatTableSQL); err != nil {
-		return fmt.Errorf("ошибка создания таблицы chats: %w", err)
-	}
-
-	// Создаем таблицу messages
-	messageTableSQL := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		message_id INTEGER NOT NULL,
-		chat_id INTEGER NOT NULL,
-		user_id INTEGER,
-		username TEXT,
-		first_name TEXT,
-		last_name TEXT,
-		text TEXT,
-		date DATETIME NOT NULL,
-		update_id INTEGER UNIQUE NOT NULL,
-		is_bot BOOLEAN NOT NULL DEFAULT 0,
-		is_addressed_to_bot BOOLEAN NOT NULL DEFAULT 0,
-		created_at DATETIME NOT NULL,
-		UNIQUE(message_id, chat_id)
-	);`
-
-	if _, err := r.db.Exec(messageTableSQL); err != nil {
-		return fmt.Errorf("ошибка создания таблицы messages: %w", err)
-	}
-
-	// Создаем индексы
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_chats_chat_id ON chats(chat_id);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_update_id ON messages(update_id);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_date ON messages(date);",
-		"CREATE INDEX IF NOT EXISTS idx_messages_text ON messages(text);",
-	}
-
-	for _, indexSQL := range indexes {
-		if _, err := r.db.Exec(indexSQL); err != nil {
-			return fmt.Errorf("ошибка создания индекса: %w", err)
-		}
+// Open открывает базу данных без применения миграций (используется командой migrate)
+func Open(dbPath string) (*SQLiteRepository, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия базы данных: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка подключения к базе данных: %w", err)
 	}
 
-	return nil
+	return &SQLiteRepository{db: db}, nil
 }
 
+// SaveChat создает или обновляет запись чата. Строка перезаписывается, только если
+// метаданные изменились, поэтому вызывать метод можно на каждое обновление.
 func (r *SQLiteRepository) SaveChat(ctx context.Context, chat *models.ChatDocument) error {
 	now := time.Now()
 
-	// Используем INSERT OR REPLACE для избежания дублирования
 	query := `
-	INSERT OR REPLACE INTO chats (
-		chat_id, type, title, username, first_name, last_name, 
+	INSERT INTO chats (
+		chat_id, type, title, username, first_name, last_name, is_forum,
 		created_at, updated_at
-	) VALUES (?, ?, ?, ?, ?, ?, 
-		COALESCE((SELECT created_at FROM chats WHERE chat_id = ?), ?), ?)`
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(chat_id) DO UPDATE SET
+		type = excluded.type,
+		title = excluded.title,
+		username = excluded.username,
+		first_name = excluded.first_name,
+		last_name = excluded.last_name,
+		is_forum = excluded.is_forum,
+		updated_at = excluded.updated_at
+	WHERE chats.type IS NOT excluded.type
+		OR chats.title IS NOT excluded.title
+		OR chats.username IS NOT excluded.username
+		OR chats.first_name IS NOT excluded.first_name
+		OR chats.last_name IS NOT excluded.last_name
+		OR chats.is_forum IS NOT excluded.is_forum`
 
 	_, err := r.db.ExecContext(ctx, query,
-		chat.ChatID, chat.Type, chat.Title, chat.Username, chat.FirstName, chat.LastName,
-		chat.ChatID, now, now)
+		chat.ChatID, chat.Type, chat.Title, chat.Username, chat.FirstName, chat.LastName, chat.IsForum,
+		now, now)
 
 	return err
 }
@@ -127,38 +116,137 @@ func (r *SQLiteRepository) SaveMessage(ctx context.Context, message *models.Mess
 	now := time.Now()
 
 	// Используем INSERT OR IGNORE для избежания дублирования сообщений
+	origin := message.Origin
+	if origin == "" {
+		origin = models.MessageOriginIncoming
+	}
+
 	query := `
 	INSERT OR IGNORE INTO messages (
 		message_id, chat_id, user_id, username, first_name, last_name,
-		text, date, update_id, is_bot, is_addressed_to_bot, created_at
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		text, date, update_id, origin, is_bot, is_addressed_to_bot, edit_date,
+		sender_chat_id, forward_from_chat_id, forward_from_message_id, is_automatic_forward,
+		reply_to_message_id, message_thread_id, topic_id, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	_, err := r.db.ExecContext(ctx, query,
 		message.MessageID, message.ChatID, message.UserID, message.Username,
 		message.FirstName, message.LastName, message.Text, message.Date,
-		message.UpdateID, message.IsBot, message.IsAddressedToBot, now)
+		nullableInt(message.UpdateID), origin, message.IsBot, message.IsAddressedToBot,
+		nullableTime(message.EditedAt), nullableInt64(message.SenderChatID),
+		nullableInt64(message.ForwardFromChatID), nullableInt(message.ForwardFromMessageID),
+		message.IsAutomaticForward, nullableInt(message.ReplyToMessageID), nullableInt(message.MessageThreadID),
+		message.TopicID, now)
 
 	return err
 }
 
-func (r *SQLiteRepository) ChatExists(ctx context.Context, chatID int64) (bool, error) {
-	query := "SELECT COUNT(*) FROM chats WHERE chat_id = ?"
+// UpdateExists проверяет, обработано ли обновление: сохранено сообщение или правка с этим update_id
+func (r *SQLiteRepository) UpdateExists(ctx context.Context, updateID int) (bool, error) {
+	query := `
+	SELECT (SELECT COUNT(*) FROM messages WHERE update_id = ?) +
+		   (SELECT COUNT(*) FROM message_edits WHERE update_id = ?)`
 	var count int
-	err := r.db.QueryRowContext(ctx, query, chatID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, updateID, updateID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (r *SQLiteRepository) UpdateExists(ctx context.Context, updateID int) (bool, error) {
-	query := "SELECT COUNT(*) FROM messages WHERE update_id = ?"
-	var count int
-	err := r.db.QueryRowContext(ctx, query, updateID).Scan(&count)
+// messageColumns - список колонок messages в порядке, который ожидает scanMessage
+const messageColumns = `id, message_id, chat_id, user_id, username, first_name, last_name,
+		   text, date, update_id, origin, is_bot, is_addressed_to_bot, edit_date,
+		   sender_chat_id, forward_from_chat_id, forward_from_message_id, is_automatic_forward,
+		   reply_to_message_id, message_thread_id, topic_id, created_at`
+
+// nullableInt превращает нулевое значение в NULL
+func nullableInt(value int) any {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+// nullableInt64 превращает нулевое значение в NULL
+func nullableInt64(value int64) any {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
+
+// nullableTime превращает нулевое время в NULL
+func nullableTime(value time.Time) any {
+	if value.IsZero() {
+		return nil
+	}
+	return value
+}
+
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// extraColumns дополняет функцию сканирования колонками до (leading) и после (extra) ее собственных
+type extraColumns struct {
+	row     rowScanner
+	leading []any
+	extra   []any
+}
+
+func withExtraColumns(row rowScanner, extra ...any) rowScanner {
+	return extraColumns{row: row, extra: extra}
+}
+
+func withLeadingColumns(row rowScanner, leading ...any) rowScanner {
+	return extraColumns{row: row, leading: leading}
+}
+
+func (s extraColumns) Scan(dest ...any) error {
+	all := append(append(append([]any{}, s.leading...), dest...), s.extra...)
+	return s.row.Scan(all...)
+}
+
+func scanMessage(row rowScanner) (*models.MessageDocument, error) {
+	msg := &models.MessageDocument{}
+	var updateID, senderChatID, forwardFromChatID, forwardFromMessageID sql.NullInt64
+	var replyToMessageID, messageThreadID sql.NullInt64
+	var editDate sql.NullTime
+	err := row.Scan(
+		&msg.ID, &msg.MessageID, &msg.ChatID, &msg.UserID, &msg.Username,
+		&msg.FirstName, &msg.LastName, &msg.Text, &msg.Date, &updateID, &msg.Origin,
+		&msg.IsBot, &msg.IsAddressedToBot, &editDate,
+		&senderChatID, &forwardFromChatID, &forwardFromMessageID, &msg.IsAutomaticForward,
+		&replyToMessageID, &messageThreadID, &msg.TopicID, &msg.CreatedAt,
+	)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return count > 0, nil
+	msg.UpdateID = int(updateID.Int64)
+	msg.EditedAt = editDate.Time
+	msg.SenderChatID = senderChatID.Int64
+	msg.ForwardFromChatID = forwardFromChatID.Int64
+	msg.ForwardFromMessageID = int(forwardFromMessageID.Int64)
+	msg.ReplyToMessageID = int(replyToMessageID.Int64)
+	msg.MessageThreadID = int(messageThreadID.Int64)
+	return msg, nil
+}
+
+func scanMessages(rows *sql.Rows) ([]*models.MessageDocument, error) {
+	defer rows.Close()
+
+	var messages []*models.MessageDocument
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
 }
 
 func (r *SQLiteRepository) GetRecentMessages(
@@ -169,33 +257,17 @@ func (r *SQLiteRepository) GetRecentMessages(
 	since := time.Now().AddDate(0, 0, -days)
 
 	query := `
-	SELECT id, message_id, chat_id, user_id, username, first_name, last_name,
-		   text, date, update_id, is_bot, is_addressed_to_bot, created_at
+	SELECT ` + messageColumns + `
 	FROM messages 
-	WHERE chat_id = ? AND date >= ?
+	WHERE chat_id IN (` + chatLineage + `) AND date >= ?
 	ORDER BY date ASC`
 
 	rows, err := r.db.QueryContext(ctx, query, chatID, since)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var messages []*models.MessageDocument
-	for rows.Next() {
-		msg := &models.MessageDocument{}
-		err := rows.Scan(
-			&msg.ID, &msg.MessageID, &msg.ChatID, &msg.UserID, &msg.Username,
-			&msg.FirstName, &msg.LastName, &msg.Text, &msg.Date, &msg.UpdateID,
-			&msg.IsBot, &msg.IsAddressedToBot, &msg.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, msg)
-	}
 
-	return messages, rows.Err()
+	return scanMessages(rows)
 }
 
 func (r *SQLiteRepository) GetLastMessages(
@@ -204,10 +276,9 @@ func (r *SQLiteRepository) GetLastMessages(
 	limit int,
 ) ([]*models.MessageDocument, error) {
 	query := `
-	SELECT id, message_id, chat_id, user_id, username, first_name, last_name,
-		   text, date, update_id, is_bot, is_addressed_to_bot, created_at
+	SELECT ` + messageColumns + `
 	FROM messages 
-	WHERE chat_id = ?
+	WHERE chat_id IN (` + chatLineage + `)
 	ORDER BY date DESC
 	LIMIT ?`
 
@@ -215,33 +286,375 @@ func (r *SQLiteRepository) GetLastMessages(
 	if err != nil {
 		return nil, err
 	}
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Поскольку мы получили сообщения в убывающем порядке,
+	// нужно развернуть их обратно для правильной хронологии
+	reverseMessages(messages)
+
+	return messages, nil
+}
+
+// GetLastTopicMessages возвращает последние сообщения одной темы форума (topicID = 0 - общая тема)
+// в хронологическом порядке
+func (r *SQLiteRepository) GetLastTopicMessages(
+	ctx context.Context,
+	chatID int64,
+	topicID int,
+	limit int,
+) ([]*models.MessageDocument, error) {
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE chat_id IN (` + chatLineage + `) AND topic_id = ?
+	ORDER BY date DESC
+	LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, topicID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	reverseMessages(messages)
+	return messages, nil
+}
+
+func reverseMessages(messages []*models.MessageDocument) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// GetMessage возвращает сообщение по его ID в чате или nil, если сообщение не сохранено
+func (r *SQLiteRepository) GetMessage(
+	ctx context.Context,
+	chatID int64,
+	messageID int,
+) (*models.MessageDocument, error) {
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE chat_id = ? AND message_id = ?`
+
+	msg, err := scanMessage(r.db.QueryRowContext(ctx, query, chatID, messageID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return msg, err
+}
+
+// GetReplyChain возвращает цепочку ответов, которая заканчивается сообщением messageID:
+// само сообщение, сообщение, на которое оно отвечает, и так далее вверх, не больше limit сообщений.
+// Сообщения упорядочены от начала цепочки к концу. Если сообщения нет в базе, возвращается nil.
+func (r *SQLiteRepository) GetReplyChain(
+	ctx context.Context,
+	chatID int64,
+	messageID int,
+	limit int,
+) ([]*models.MessageDocument, error) {
+	query := `
+	WITH RECURSIVE chain(id, reply_to_message_id, depth) AS (
+		SELECT id, reply_to_message_id, 1
+		FROM messages
+		WHERE chat_id = ? AND message_id = ?
+		UNION ALL
+		SELECT messages.id, messages.reply_to_message_id, chain.depth + 1
+		FROM messages
+		JOIN chain ON messages.chat_id = ? AND messages.message_id = chain.reply_to_message_id
+		WHERE chain.depth < ?
+	)
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE id IN (SELECT id FROM chain)
+	ORDER BY date ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, messageID, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMessages(rows)
+}
+
+// updateOffsetKey - ключ в bot_state, под которым хранится offset для getUpdates
+const updateOffsetKey = "update_offset"
+
+// GetUpdateOffset возвращает сохраненный offset для getUpdates (0, если он еще не сохранялся)
+func (r *SQLiteRepository) GetUpdateOffset(ctx context.Context) (int, error) {
+	query := "SELECT value FROM bot_state WHERE key = ?"
+	var value string
+	err := r.db.QueryRowContext(ctx, query, updateOffsetKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный offset в bot_state: %w", err)
+	}
+	return offset, nil
+}
+
+// SaveUpdateOffset сохраняет offset для getUpdates
+func (r *SQLiteRepository) SaveUpdateOffset(ctx context.Context, offset int) error {
+	query := `
+	INSERT INTO bot_state (key, value, updated_at) VALUES (?, ?, ?)
+	ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query, updateOffsetKey, strconv.Itoa(offset), time.Now())
+	return err
+}
+
+// GetChatSettings возвращает настройки чата (topicID = 0) или темы форума и nil, если они не заданы.
+// Настройки темы не включают настройки чата - их объединяет models.MergeChatSettings.
+// Если у чата своих настроек нет, действуют настройки группы, из которой он преобразован.
+func (r *SQLiteRepository) GetChatSettings(ctx context.Context, chatID int64, topicID int) (*models.ChatSettings, error) {
+	query := chatLineageCTE + `
+	SELECT chat_settings.chat_id, topic_id, persona_prompt, model, context_tokens, ambient_ratio,
+		temperature, top_p, max_tokens, stop_sequences, presence_penalty, frequency_penalty, seed,
+		created_at, updated_at
+	FROM chat_settings
+	JOIN lineage ON lineage.chat_id = chat_settings.chat_id
+	WHERE topic_id = ?
+	ORDER BY lineage.depth
+	LIMIT 1`
+
+	settings := &models.ChatSettings{}
+	var ambientRatio, temperature, topP, presencePenalty, frequencyPenalty sql.NullFloat64
+	var contextTokens, maxTokens, seed sql.NullInt64
+	var stop sql.NullString
+	err := r.db.QueryRowContext(ctx, query, chatID, topicID).Scan(
+		&settings.ChatID, &settings.TopicID, &settings.PersonaPrompt, &settings.Model, &contextTokens, &ambientRatio,
+		&temperature, &topP, &maxTokens, &stop, &presencePenalty, &frequencyPenalty, &seed,
+		&settings.CreatedAt, &settings.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Настройки, унаследованные от группы, сохраняются уже под ID самого чата
+	settings.ChatID = chatID
+
+	settings.ContextTokens = intPointer(contextTokens)
+	settings.AmbientRatio = floatPointer(ambientRatio)
+	settings.Temperature = floatPointer(temperature)
+	settings.TopP = floatPointer(topP)
+	settings.MaxTokens = intPointer(maxTokens)
+	settings.PresencePenalty = floatPointer(presencePenalty)
+	settings.FrequencyPenalty = floatPointer(frequencyPenalty)
+	settings.Seed = intPointer(seed)
+	if stop.Valid {
+		if err := json.Unmarshal([]byte(stop.String), &settings.Stop); err != nil {
+			return nil, fmt.Errorf("ошибка чтения стоп-последовательностей: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+// SaveChatSettings создает или обновляет настройки чата или темы (settings.TopicID)
+func (r *SQLiteRepository) SaveChatSettings(ctx context.Context, settings *models.ChatSettings) error {
+	now := time.Now()
+
+	var stop any
+	if len(settings.Stop) > 0 {
+		data, err := json.Marshal(settings.Stop)
+		if err != nil {
+			return fmt.Errorf("ошибка кодирования стоп-последовательностей: %w", err)
+		}
+		stop = string(data)
+	}
+
+	query := `
+	INSERT INTO chat_settings (
+		chat_id, topic_id, persona_prompt, model, context_tokens, ambient_ratio,
+		temperature, top_p, max_tokens, stop_sequences, presence_penalty, frequency_penalty, seed,
+		created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(chat_id, topic_id) DO UPDATE SET
+		persona_prompt = excluded.persona_prompt,
+		model = excluded.model,
+		context_tokens = excluded.context_tokens,
+		ambient_ratio = excluded.ambient_ratio,
+		temperature = excluded.temperature,
+		top_p = excluded.top_p,
+		max_tokens = excluded.max_tokens,
+		stop_sequences = excluded.stop_sequences,
+		presence_penalty = excluded.presence_penalty,
+		frequency_penalty = excluded.frequency_penalty,
+		seed = excluded.seed,
+		updated_at = excluded.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		settings.ChatID, settings.TopicID, settings.PersonaPrompt, settings.Model,
+		settings.ContextTokens, settings.AmbientRatio,
+		settings.Temperature, settings.TopP, settings.MaxTokens, stop,
+		settings.PresencePenalty, settings.FrequencyPenalty, settings.Seed,
+		now, now)
+
+	return err
+}
+
+// floatPointer превращает NULL в nil
+func floatPointer(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// intPointer превращает NULL в nil
+func intPointer(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
+
+// DeleteChatSettings удаляет настройки чата (topicID = 0) или темы, возвращая их к значениям уровнем выше.
+// Удаляются и настройки, унаследованные от группы, из которой преобразован чат.
+func (r *SQLiteRepository) DeleteChatSettings(ctx context.Context, chatID int64, topicID int) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM chat_settings WHERE chat_id IN ("+chatLineage+") AND topic_id = ?", chatID, topicID)
+	return err
+}
+
+// ResetContext запоминает момент, раньше которого сообщения чата (topicID = 0) или темы форума
+// не попадают в контекст LLM
+func (r *SQLiteRepository) ResetContext(ctx context.Context, chatID int64, topicID int, at time.Time) error {
+	query := `
+	INSERT INTO context_resets (chat_id, topic_id, reset_at) VALUES (?, ?, ?)
+	ON CONFLICT(chat_id, topic_id) DO UPDATE SET reset_at = excluded.reset_at`
+
+	_, err := r.db.ExecContext(ctx, query, chatID, topicID, at)
+	return err
+}
+
+// GetContextResetTime возвращает момент последнего сброса контекста чата или темы, учитывая сбросы
+// в группе, из которой преобразован чат (нулевое время, если сброса не было)
+func (r *SQLiteRepository) GetContextResetTime(ctx context.Context, chatID int64, topicID int) (time.Time, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT reset_at FROM context_resets WHERE chat_id IN ("+chatLineage+") AND topic_id = ?", chatID, topicID)
+	if err != nil {
+		return time.Time{}, err
+	}
 	defer rows.Close()
 
-	var messages []*models.MessageDocument
+	var last time.Time
 	for rows.Next() {
-		msg := &models.MessageDocument{}
-		err := rows.Scan(
-			&msg.ID, &msg.MessageID, &msg.ChatID, &msg.UserID, &msg.Username,
-			&msg.FirstName, &msg.LastName, &msg.Text, &msg.Date, &msg.UpdateID,
-			&msg.IsBot, &msg.IsAddressedToBot, &msg.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
+		var resetAt time.Time
+		if err := rows.Scan(&resetAt); err != nil {
+			return time.Time{}, err
+		}
+		if resetAt.After(last) {
+			last = resetAt
 		}
-		messages = append(messages, msg)
 	}
+	return last, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
+// GetChatStats возвращает статистику сообщений чата
+func (r *SQLiteRepository) GetChatStats(ctx context.Context, chatID int64) (*models.ChatStats, error) {
+	query := `
+	SELECT
+		COUNT(*),
+		COALESCE(SUM(origin = 'outgoing'), 0),
+		COALESCE(SUM(is_addressed_to_bot), 0),
+		COUNT(DISTINCT CASE WHEN is_bot = 0 THEN user_id END),
+		MIN(date),
+		MAX(date)
+	FROM messages
+	WHERE chat_id IN (` + chatLineage + `)`
+
+	stats := &models.ChatStats{}
+	var first, last sql.NullString
+	err := r.db.QueryRowContext(ctx, query, chatID).Scan(
+		&stats.TotalMessages, &stats.BotMessages, &stats.AddressedMessages,
+		&stats.Participants, &first, &last,
+	)
+	if err != nil {
 		return nil, err
 	}
 
-	// Поскольку мы получили сообщения в убывающем порядке,
-	// нужно развернуть их обратно для правильной хронологии
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	// Агрегатные функции SQLite возвращают дату строкой, поэтому разбираем ее вручную
+	if stats.FirstMessageAt, err = parseSQLiteTime(first); err != nil {
+		return nil, err
+	}
+	if stats.LastMessageAt, err = parseSQLiteTime(last); err != nil {
+		return nil, err
 	}
+	return stats, nil
+}
 
-	return messages, nil
+// parseSQLiteTime разбирает дату в формате, в котором ее сохраняет драйвер go-sqlite3
+func parseSQLiteTime(value sql.NullString) (time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, value.String, time.UTC); err == nil {
+			return t.Local(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("не удалось разобрать дату %q", value.String)
+}
+
+// GetChatTriggers возвращает дополнительные слова-триггеры чата, включая слова группы,
+// из которой он преобразован
+func (r *SQLiteRepository) GetChatTriggers(ctx context.Context, chatID int64) ([]string, error) {
+	query := "SELECT DISTINCT word FROM chat_triggers WHERE chat_id IN (" + chatLineage + ") ORDER BY word"
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+
+	return words, rows.Err()
+}
+
+// AddChatTrigger добавляет слово-триггер чата (повторное добавление игнорируется)
+func (r *SQLiteRepository) AddChatTrigger(ctx context.Context, chatID int64, word string) error {
+	query := "INSERT OR IGNORE INTO chat_triggers (chat_id, word, created_at) VALUES (?, ?, ?)"
+	_, err := r.db.ExecContext(ctx, query, chatID, word, time.Now())
+	return err
+}
+
+// DeleteChatTrigger удаляет слово-триггер чата (и унаследованное от группы). Возвращает false, если такого слова не было.
+func (r *SQLiteRepository) DeleteChatTrigger(ctx context.Context, chatID int64, word string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM chat_triggers WHERE chat_id IN ("+chatLineage+") AND word = ?", chatID, word)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
 func (r *SQLiteRepository) Close(ctx context.Context) error {
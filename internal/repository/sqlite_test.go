@@ -0,0 +1,393 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+func newTestRepository(t *testing.T) *SQLiteRepository {
+	t.Helper()
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "sueta.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close(context.Background()) })
+	return repo
+}
+
+// schema описывает схему базы: столбцы каждой таблицы, индексы и триггеры
+func schema(t *testing.T, repo *SQLiteRepository) string {
+	t.Helper()
+	rows, err := repo.db.Query(`
+	SELECT m.type, m.name, COALESCE(p.name, '')
+	FROM sqlite_master m
+	LEFT JOIN pragma_table_info(m.name) p ON m.type = 'table'
+	WHERE m.name NOT LIKE 'sqlite_%'
+	ORDER BY m.type, m.name, p.cid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	for rows.Next() {
+		var kind, name, column string
+		if err := rows.Scan(&kind, &name, &column); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", kind, name, column)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full := schema(t, repo)
+
+	// Откат любого числа последних миграций и повторное применение восстанавливают схему,
+	// а откат до одной и той же версии всегда дает одну и ту же схему
+	previous := full
+	for steps := 1; steps <= len(migrations); steps++ {
+		m := migrations[len(migrations)-steps]
+		if n, err := repo.MigrateDown(ctx, steps); err != nil || n != steps {
+			t.Fatalf("откат до %04d_%s: %d, %v", m.Version, m.Name, n, err)
+		}
+		rolledBack := schema(t, repo)
+		if rolledBack == previous {
+			t.Errorf("откат %04d_%s не изменил схему", m.Version, m.Name)
+		}
+
+		if n, err := repo.MigrateUp(ctx); err != nil || n != steps {
+			t.Fatalf("применение после отката %04d_%s: %d, %v", m.Version, m.Name, n, err)
+		}
+		if got := schema(t, repo); got != full {
+			t.Errorf("после отката %04d_%s и повторного применения схема изменилась:\n%s\nбыло:\n%s",
+				m.Version, m.Name, got, full)
+		}
+
+		// Откат по одной миграции приходит к той же схеме
+		if _, err := repo.MigrateDown(ctx, steps-1); err != nil {
+			t.Fatal(err)
+		}
+		if got := schema(t, repo); got != previous {
+			t.Errorf("откат до %04d_%s по одной миграции дал другую схему", m.Version, m.Name)
+		}
+		if _, err := repo.MigrateDown(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		if got := schema(t, repo); got != rolledBack {
+			t.Errorf("повторный откат %04d_%s дал другую схему", m.Version, m.Name)
+		}
+		if _, err := repo.MigrateUp(ctx); err != nil {
+			t.Fatal(err)
+		}
+		previous = rolledBack
+	}
+
+	statuses, err := repo.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("миграция %04d_%s не применена", status.Version, status.Name)
+		}
+	}
+}
+
+func TestMigrationsKeepData(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	saveTestMessages(t, repo, -1, "сообщение до отката")
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Откатываем все миграции, которые появились после таблицы messages_fts
+	steps := len(migrations) - 7
+	if _, err := repo.MigrateDown(ctx, steps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := repo.GetLastMessages(ctx, -1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Text != "сообщение до отката" {
+		t.Errorf("после отката и применения миграций сообщения: %+v", messages)
+	}
+}
+
+// saveTestMessages сохраняет сообщения чата с номерами от 1 по порядку
+func saveTestMessages(t *testing.T, repo *SQLiteRepository, chatID int64, texts ...string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.SaveChat(ctx, &models.ChatDocument{ChatID: chatID, Type: "group"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var updateID int
+	if err := repo.db.QueryRow("SELECT COALESCE(MAX(update_id), 0) FROM messages").Scan(&updateID); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, text := range texts {
+		updateID++
+		err := repo.SaveMessage(ctx, &models.MessageDocument{
+			MessageID: i + 1,
+			ChatID:    chatID,
+			UserID:    42,
+			FirstName: "Вася",
+			Text:      text,
+			Date:      start.Add(time.Duration(updateID) * time.Second),
+			UpdateID:  updateID,
+			Origin:    models.MessageOriginIncoming,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	saveTestMessages(t, repo, -1,
+		"Жорик пришел",
+		"у Жорика дома",
+		"Жора ушел",
+		"ёжик в тумане",
+		"/search жорик",
+		"Жорик и ёжик",
+	)
+	saveTestMessages(t, repo, -2, "Жорик из другого чата")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "жорика", want: []string{"Жорик пришел", "у Жорика дома", "Жорик и ёжик"}},
+		{query: "ЁЖИК", want: []string{"ёжик в тумане", "Жорик и ёжик"}},
+		{query: "ежиков", want: []string{"ёжик в тумане", "Жорик и ёжик"}},
+		{query: "жорик ежик", want: []string{"Жорик и ёжик"}},
+		{query: "жора", want: []string{"Жорик пришел", "у Жорика дома", "Жора ушел", "Жорик и ёжик"}},
+		{query: "пингвин", want: nil},
+		{query: `"`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := repo.SearchMessages(ctx, -1, tt.query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Message.Text)
+			}
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("SearchMessages(%q) = %q, ожидалось %q", tt.query, got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateChat(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	const group, supergroup = -1, -1001
+
+	// Номера сообщений группы и супергруппы пересекаются
+	saveTestMessages(t, repo, group, "старое первое", "старое второе")
+	if err := repo.AddChatTrigger(ctx, group, "жорик"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveChatSettings(ctx, &models.ChatSettings{ChatID: group, PersonaPrompt: "персона группы"}); err != nil {
+		t.Fatal(err)
+	}
+	resetAt := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := repo.ResetContext(ctx, group, 0, resetAt); err != nil {
+		t.Fatal(err)
+	}
+	joined := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	vasya := &models.UserDocument{UserID: 42, FirstName: "Вася"}
+	if err := repo.SaveUser(ctx, vasya, group, joined); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.MigrateChat(ctx, group, supergroup); err != nil {
+			t.Fatalf("MigrateChat (вызов %d): %v", i+1, err)
+		}
+	}
+	saveTestMessages(t, repo, supergroup, "новое первое")
+
+	messages, err := repo.GetLastMessages(ctx, supergroup, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, msg := range messages {
+		texts = append(texts, msg.Text)
+	}
+	if want := []string{"старое первое", "старое второе", "новое первое"}; !slices.Equal(texts, want) {
+		t.Errorf("история супергруппы %q, ожидалось %q", texts, want)
+	}
+
+	// Сообщения группы остались под ее ID
+	if old, err := repo.GetMessage(ctx, group, 1); err != nil || old == nil || old.Text != "старое первое" {
+		t.Errorf("сообщение группы: %+v, %v", old, err)
+	}
+	if msg, err := repo.GetMessage(ctx, supergroup, 1); err != nil || msg == nil || msg.Text != "новое первое" {
+		t.Errorf("сообщение супергруппы: %+v, %v", msg, err)
+	}
+
+	triggers, err := repo.GetChatTriggers(ctx, supergroup)
+	if err != nil || !slices.Equal(triggers, []string{"жорик"}) {
+		t.Errorf("слова-триггеры супергруппы: %v, %v", triggers, err)
+	}
+	settings, err := repo.GetChatSettings(ctx, supergroup, 0)
+	if err != nil || settings == nil || settings.PersonaPrompt != "персона группы" {
+		t.Errorf("настройки супергруппы: %+v, %v", settings, err)
+	}
+
+	stats, err := repo.GetChatStats(ctx, supergroup)
+	if err != nil || stats.TotalMessages != 3 {
+		t.Errorf("статистика супергруппы: %+v, %v", stats, err)
+	}
+	results, err := repo.SearchMessages(ctx, supergroup, "старое", 10)
+	if err != nil || len(results) != 2 {
+		t.Errorf("поиск по истории группы: %d результатов, %v", len(results), err)
+	}
+
+	if got, err := repo.GetContextResetTime(ctx, supergroup, 0); err != nil || !got.Equal(resetAt) {
+		t.Errorf("сброс контекста супергруппы: %s, %v", got, err)
+	}
+
+	// Участник группы, написавший и в супергруппу, - один участник с датами по обоим чатам
+	lastSeen := joined.Add(24 * time.Hour)
+	if err := repo.SaveUser(ctx, vasya, supergroup, lastSeen); err != nil {
+		t.Fatal(err)
+	}
+	members, err := repo.ListChatMembers(ctx, supergroup)
+	if err != nil || len(members) != 1 {
+		t.Fatalf("участники супергруппы: %+v, %v", members, err)
+	}
+	if m := members[0]; m.ChatID != supergroup || m.User.UserID != 42 ||
+		!m.FirstSeenAt.Equal(joined) || !m.LastSeenAt.Equal(lastSeen) {
+		t.Errorf("участник супергруппы: %+v", m)
+	}
+
+	// Унаследованные настройки и слова удаляются из супергруппы
+	if deleted, err := repo.DeleteChatTrigger(ctx, supergroup, "жорик"); err != nil || !deleted {
+		t.Errorf("удаление слова группы: %t, %v", deleted, err)
+	}
+	if err := repo.DeleteChatSettings(ctx, supergroup, 0); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err := repo.GetChatSettings(ctx, supergroup, 0); err != nil || settings != nil {
+		t.Errorf("настройки супергруппы после сброса: %+v, %v", settings, err)
+	}
+}
+
+func TestContextResets(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	chatReset := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	topicReset := chatReset.Add(time.Hour)
+
+	if err := repo.ResetContext(ctx, -1, 0, chatReset); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetContext(ctx, -1, 7, topicReset); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		chatID  int64
+		topicID int
+		want    time.Time
+	}{
+		{name: "общая тема", chatID: -1, topicID: 0, want: chatReset},
+		{name: "тема со сбросом", chatID: -1, topicID: 7, want: topicReset},
+		{name: "тема без сброса", chatID: -1, topicID: 8},
+		{name: "другой чат", chatID: -2, topicID: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetContextResetTime(ctx, tt.chatID, tt.topicID)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("GetContextResetTime = %s, %v, ожидалось %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditMessageOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	saveTestMessages(t, repo, -1, "исходный")
+	sent := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	edits := []*models.MessageEdit{
+		{UpdateID: 100, Text: "третий", EditedAt: sent.Add(3 * time.Minute)},
+		{UpdateID: 101, Text: "первый", EditedAt: sent.Add(time.Minute)},
+		{UpdateID: 102, Text: "второй", EditedAt: sent.Add(2 * time.Minute)},
+		{UpdateID: 101, Text: "первый", EditedAt: sent.Add(time.Minute)},     // Повторная доставка
+		{UpdateID: 103, Text: "третий", EditedAt: sent.Add(4 * time.Minute)}, // Текст не изменился
+	}
+	for _, edit := range edits {
+		edit.ChatID, edit.MessageID = -1, 1
+		if found, err := repo.EditMessage(ctx, edit); err != nil || !found {
+			t.Fatalf("EditMessage(%d): %t, %v", edit.UpdateID, found, err)
+		}
+	}
+
+	msg, err := repo.GetMessage(ctx, -1, 1)
+	if err != nil || msg.Text != "третий" {
+		t.Errorf("текст сообщения: %+v, %v", msg, err)
+	}
+
+	history, err := repo.GetMessageEdits(ctx, -1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, edit := range history {
+		got = append(got, edit.PreviousText+" → "+edit.Text)
+	}
+	want := []string{"исходный → первый", "первый → второй", "второй → третий"}
+	if !slices.Equal(got, want) {
+		t.Errorf("история правок %q, ожидалось %q", got, want)
+	}
+
+	if found, err := repo.EditMessage(ctx, &models.MessageEdit{ChatID: -1, MessageID: 2, Text: "нет такого"}); err != nil || found {
+		t.Errorf("правка несохраненного сообщения: %t, %v", found, err)
+	}
+}
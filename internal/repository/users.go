@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/semyon-ancherbak/sueta/internal/models"
+)
+
+// SaveUser обновляет текущее имя пользователя, записывает смену имени в историю
+// и отмечает пользователя участником чата (chatID = 0 - не отмечать). Все изменения
+// выполняются в одной транзакции. seenAt - время сообщения, в котором замечен пользователь.
+func (r *SQLiteRepository) SaveUser(ctx context.Context, user *models.UserDocument, chatID int64, seenAt time.Time) error {
+	if user.UserID == 0 {
+		return nil
+	}
+
+	return r.inTransaction(ctx, func(tx *sql.Tx) error {
+		var username, firstName, lastName string
+		err := tx.QueryRowContext(ctx,
+			"SELECT username, first_name, last_name FROM users WHERE user_id = ?", user.UserID,
+		).Scan(&username, &firstName, &lastName)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		renamed := username != user.Username || firstName != user.FirstName || lastName != user.LastName
+		if err == sql.ErrNoRows || renamed {
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_name_history (user_id, username, first_name, last_name, seen_at)
+			VALUES (?, ?, ?, ?, ?)`,
+				user.UserID, user.Username, user.FirstName, user.LastName, seenAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO users (
+			user_id, username, first_name, last_name, is_bot, first_seen_at, last_seen_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			username = excluded.username,
+			first_name = excluded.first_name,
+			last_name = excluded.last_name,
+			is_bot = excluded.is_bot,
+			last_seen_at = MAX(users.last_seen_at, excluded.last_seen_at),
+			updated_at = excluded.updated_at`,
+			user.UserID, user.Username, user.FirstName, user.LastName, user.IsBot,
+			seenAt, seenAt, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if chatID == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO chat_members (chat_id, user_id, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, user_id) DO UPDATE SET
+			last_seen_at = MAX(chat_members.last_seen_at, excluded.last_seen_at)`,
+			chatID, user.UserID, seenAt, seenAt)
+		return err
+	})
+}
+
+// userColumns - список колонок users в порядке, который ожидает scanUser
+const userColumns = `users.user_id, users.username, users.first_name, users.last_name, users.is_bot,
+		   users.first_seen_at, users.last_seen_at, users.updated_at`
+
+func scanUser(row rowScanner) (*models.UserDocument, error) {
+	user := &models.UserDocument{}
+	err := row.Scan(
+		&user.UserID, &user.Username, &user.FirstName, &user.LastName, &user.IsBot,
+		&user.FirstSeenAt, &user.LastSeenAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser возвращает пользователя по ID (nil, если пользователь еще не встречался)
+func (r *SQLiteRepository) GetUser(ctx context.Context, userID int64) (*models.UserDocument, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE user_id = ?"
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// GetUserNameHistory возвращает все варианты имени пользователя в порядке появления
+func (r *SQLiteRepository) GetUserNameHistory(ctx context.Context, userID int64) ([]*models.UserNameChange, error) {
+	query := `
+	SELECT user_id, username, first_name, last_name, seen_at
+	FROM user_name_history
+	WHERE user_id = ?
+	ORDER BY seen_at ASC, id ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*models.UserNameChange
+	for rows.Next() {
+		change := &models.UserNameChange{}
+		err := rows.Scan(&change.UserID, &change.Username, &change.FirstName, &change.LastName, &change.SeenAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// ListChatMembers возвращает пользователей, писавших в чат или в группу, из которой он преобразован,
+// начиная с последних активных
+func (r *SQLiteRepository) ListChatMembers(ctx context.Context, chatID int64) ([]*models.ChatMember, error) {
+	query := `
+	SELECT MIN(chat_members.first_seen_at), MAX(chat_members.last_seen_at) AS last_seen, ` + userColumns + `
+	FROM chat_members
+	JOIN users ON users.user_id = chat_members.user_id
+	WHERE chat_members.chat_id IN (` + chatLineage + `)
+	GROUP BY users.user_id
+	ORDER BY last_seen DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.ChatMember
+	for rows.Next() {
+		member := &models.ChatMember{ChatID: chatID}
+		// У агрегатов нет типа столбца, поэтому даты приходят строками
+		var firstSeen, lastSeen sql.NullString
+		user, err := scanUser(withLeadingColumns(rows, &firstSeen, &lastSeen))
+		if err != nil {
+			return nil, err
+		}
+		if member.FirstSeenAt, err = parseSQLiteTime(firstSeen); err != nil {
+			return nil, err
+		}
+		if member.LastSeenAt, err = parseSQLiteTime(lastSeen); err != nil {
+			return nil, err
+		}
+		member.User = user
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// GetUserStats возвращает статистику сообщений пользователя в чате (chatID = 0 - по всем чатам)
+func (r *SQLiteRepository) GetUserStats(ctx context.Context, chatID, userID int64) (*models.UserStats, error) {
+	query := `
+	SELECT
+		COUNT(*),
+		COALESCE(SUM(is_addressed_to_bot), 0),
+		MIN(date),
+		MAX(date)
+	FROM messages
+	WHERE user_id = ? AND (? = 0 OR chat_id IN (` + chatLineage + `))`
+
+	stats := &models.UserStats{UserID: userID, ChatID: chatID}
+	var first, last sql.NullString
+	err := r.db.QueryRowContext(ctx, query, userID, chatID, chatID).Scan(
+		&stats.TotalMessages, &stats.AddressedMessages, &first, &last,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.FirstMessageAt, err = parseSQLiteTime(first); err != nil {
+		return nil, err
+	}
+	if stats.LastMessageAt, err = parseSQLiteTime(last); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
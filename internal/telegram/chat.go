@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+)
+
+// Статусы участника чата
+const (
+	MemberStatusCreator       = "creator"
+	MemberStatusAdministrator = "administrator"
+	MemberStatusMember        = "member"
+	MemberStatusRestricted    = "restricted"
+	MemberStatusLeft          = "left"
+	MemberStatusKicked        = "kicked"
+)
+
+// GetChatMemberRequest представляет запрос информации об участнике чата
+type GetChatMemberRequest struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int64 `json:"user_id"`
+}
+
+// ChatMember представляет участника чата
+type ChatMember struct {
+	Status string `json:"status"`
+	User   *User  `json:"user"`
+}
+
+// IsAdmin сообщает, является ли участник создателем или администратором чата
+func (m *ChatMember) IsAdmin() bool {
+	return m.Status == MemberStatusCreator || m.Status == MemberStatusAdministrator
+}
+
+// GetChatMember возвращает информацию об участнике чата
+func (c *Client) GetChatMember(ctx context.Context, chatID, userID int64) (*ChatMember, error) {
+	var member ChatMember
+	request := GetChatMemberRequest{ChatID: chatID, UserID: userID}
+	if err := c.call(ctx, "getChatMember", request, &member); err != nil {
+		return nil, fmt.Errorf("ошибка получения участника чата: %w", err)
+	}
+	return &member, nil
+}
+
+// GetMe возвращает информацию о самом боте
+func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.call(ctx, "getMe", struct{}{}, &user); err != nil {
+		return nil, fmt.Errorf("ошибка получения информации о боте: %w", err)
+	}
+	return &user, nil
+}
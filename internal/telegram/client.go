@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/semyon-ancherbak/sueta/internal/models"
@@ -17,43 +18,42 @@ type Client struct {
 	token      string
 	baseURL    string
 	httpClient *http.Client
+	pollClient *http.Client          // Клиент без общего таймаута для long polling
 	repo       repository.Repository // Добавляем репозиторий для сохранения сообщений
 }
 
-func NewClient(token string, repo repository.Repository) *Client {
+// NewClient создает клиента Bot API по адресу apiURL (https://api.telegram.org или локальный Bot API сервер)
+func NewClient(apiURL, token string, repo repository.Repository) *Client {
 	return &Client{
 		token:   token,
-		baseURL: "https://api.telegram.org/bot" + token,
+		baseURL: strings.TrimRight(apiURL, "/") + "/bot" + token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		repo: repo,
+		pollClient: &http.Client{},
+		repo:       repo,
 	}
 }
 
 // SendMessageRequest представляет запрос для отправки сообщения
 type SendMessageRequest struct {
 	ChatID           int64  `json:"chat_id"`
+	MessageThreadID  int    `json:"message_thread_id,omitempty"` // Тема форума (0 - общая тема)
 	Text             string `json:"text"`
 	ParseMode        string `json:"parse_mode,omitempty"`
 	ReplyToMessageID int    `json:"reply_to_message_id,omitempty"`
 }
 
-// SendMessageResponse представляет ответ на отправку сообщения
-type SendMessageResponse struct {
-	OK          bool     `json:"ok"`
-	Result      *Message `json:"result,omitempty"`
-	Description string   `json:"description,omitempty"`
-	ErrorCode   int      `json:"error_code,omitempty"`
-}
-
 // Message представляет сообщение в Telegram
 type Message struct {
-	MessageID int    `json:"message_id"`
-	From      *User  `json:"from,omitempty"`
-	Chat      *Chat  `json:"chat,omitempty"`
-	Date      int64  `json:"date"`
-	Text      string `json:"text,omitempty"`
+	MessageID       int      `json:"message_id"`
+	From            *User    `json:"from,omitempty"`
+	Chat            *Chat    `json:"chat,omitempty"`
+	Date            int64    `json:"date"`
+	Text            string   `json:"text,omitempty"`
+	ReplyToMessage  *Message `json:"reply_to_message,omitempty"`
+	MessageThreadID int      `json:"message_thread_id,omitempty"`
+	IsTopicMessage  bool     `json:"is_topic_message,omitempty"`
 }
 
 // User представляет пользователя Telegram
@@ -72,23 +72,98 @@ type Chat struct {
 	Title string `json:"title,omitempty"`
 }
 
-// SendMessage отправляет сообщение в указанный чат
-func (c *Client) SendMessage(ctx context.Context, chatID int64, text string, replyToMessageID int) error {
+// APIResponse представляет общий ответ Telegram Bot API
+type APIResponse struct {
+	OK          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	Description string              `json:"description,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+// ResponseParameters содержит дополнительные сведения об ошибке запроса
+type ResponseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
+}
+
+// Error - ошибка, которую вернул Telegram Bot API
+type Error struct {
+	Code        int
+	Description string
+	// RetryAfter - через сколько можно повторить запрос после превышения лимитов (ошибка 429)
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram API вернул ошибку %d: %s", e.Code, e.Description)
+}
+
+// SendMessage отправляет сообщение в указанный чат (и тему форума, если topicID не 0)
+// и сохраняет его в истории как реплику бота
+func (c *Client) SendMessage(ctx context.Context, chatID int64, topicID int, text string, replyToMessageID int) error {
+	result, err := c.sendMessage(ctx, chatID, topicID, text, replyToMessageID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.saveBotMessage(ctx, result, text); err != nil {
+		// Логируем ошибку, но не возвращаем её, так как сообщение уже отправлено
+		fmt.Printf("Ошибка сохранения сообщения бота: %v\n", err)
+	}
+
+	return nil
+}
+
+// SendServiceMessage отправляет служебное сообщение (например, ответ на команду).
+// Такие сообщения не сохраняются в истории и не попадают в контекст LLM.
+func (c *Client) SendServiceMessage(ctx context.Context, chatID int64, topicID int, text string, replyToMessageID int) error {
+	_, err := c.sendMessage(ctx, chatID, topicID, text, replyToMessageID)
+	return err
+}
+
+func (c *Client) sendMessage(
+	ctx context.Context,
+	chatID int64,
+	topicID int,
+	text string,
+	replyToMessageID int,
+) (*Message, error) {
 	request := SendMessageRequest{
-		ChatID: chatID,
-		Text:   text,
+		ChatID:          chatID,
+		MessageThreadID: topicID,
+		Text:            text,
 	}
 
 	if replyToMessageID > 0 {
 		request.ReplyToMessageID = replyToMessageID
 	}
 
+	var result Message
+	if err := c.call(ctx, "sendMessage", request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// call выполняет метод Bot API и декодирует поле result в result (если не nil)
+func (c *Client) call(ctx context.Context, method string, request any, result any) error {
+	return c.callWithClient(ctx, c.httpClient, method, request, result)
+}
+
+func (c *Client) callWithClient(
+	ctx context.Context,
+	httpClient *http.Client,
+	method string,
+	request any,
+	result any,
+) error {
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("ошибка кодирования JSON: %w", err)
 	}
 
-	url := c.baseURL + "/sendMessage"
+	url := c.baseURL + "/" + method
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("ошибка создания запроса: %w", err)
@@ -96,7 +171,7 @@ func (c *Client) SendMessage(ctx context.Context, chatID int64, text string, rep
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("ошибка выполнения HTTP запроса: %w", err)
 	}
@@ -107,27 +182,36 @@ func (c *Client) SendMessage(ctx context.Context, chatID int64, text string, rep
 		return fmt.Errorf("ошибка чтения ответа: %w", err)
 	}
 
-	var response SendMessageResponse
+	var response APIResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		return fmt.Errorf("ошибка парсинга ответа: %w", err)
 	}
 
 	if !response.OK {
-		return fmt.Errorf("telegram API вернул ошибку %d: %s", response.ErrorCode, response.Description)
+		apiErr := &Error{Code: response.ErrorCode, Description: response.Description}
+		if response.Parameters != nil {
+			apiErr.RetryAfter = time.Duration(response.Parameters.RetryAfter) * time.Second
+		}
+		return apiErr
 	}
 
-	if response.Result != nil {
-		if err := c.saveBotMessage(ctx, response.Result, text); err != nil {
-			// Логируем ошибку, но не возвращаем её, так как сообщение уже отправлено
-			fmt.Printf("Ошибка сохранения сообщения бота: %v\n", err)
+	if result != nil && len(response.Result) > 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("ошибка парсинга результата %s: %w", method, err)
 		}
 	}
 
 	return nil
 }
 
+// SaveBotMessage сохраняет в истории окончательный текст отправленного ботом сообщения
+// (например, заглушки, дописанной через EditMessageText)
+func (c *Client) SaveBotMessage(ctx context.Context, msg *Message, text string) error {
+	return c.saveBotMessage(ctx, msg, text)
+}
+
 func (c *Client) saveBotMessage(ctx context.Context, msg *Message, text string) error {
-	if msg == nil || c.repo == nil {
+	if msg == nil || msg.Chat == nil || c.repo == nil {
 		return nil
 	}
 
@@ -136,9 +220,17 @@ func (c *Client) saveBotMessage(ctx context.Context, msg *Message, text string)
 		ChatID:    msg.Chat.ID,
 		Text:      text,
 		Date:      time.Unix(msg.Date, 0),
-		UpdateID:  0,    // Для сообщений бота UpdateID = 0
-		IsBot:     true, // Помечаем как сообщение от бота
+		Origin:    models.MessageOriginOutgoing, // У отправленных ботом сообщений нет update_id
+		IsBot:     true,
 		CreatedAt: time.Now(),
+
+		MessageThreadID: msg.MessageThreadID,
+	}
+	if msg.IsTopicMessage {
+		messageDoc.TopicID = msg.MessageThreadID
+	}
+	if msg.ReplyToMessage != nil {
+		messageDoc.ReplyToMessageID = msg.ReplyToMessage.MessageID
 	}
 
 	// Добавляем информацию о боте как пользователе
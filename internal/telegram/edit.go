@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// MaxMessageLength - предельная длина текста сообщения в Telegram (в единицах UTF-16)
+const MaxMessageLength = 4096
+
+// EditMessageTextRequest представляет запрос на изменение текста сообщения
+type EditMessageTextRequest struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// DeleteMessageRequest представляет запрос на удаление сообщения
+type DeleteMessageRequest struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+}
+
+// SendPlaceholder отправляет сообщение, текст которого затем дописывается через EditMessageText.
+// Такое сообщение не сохраняется в истории: окончательный текст сохраняет SaveBotMessage.
+func (c *Client) SendPlaceholder(
+	ctx context.Context,
+	chatID int64,
+	topicID int,
+	text string,
+	replyToMessageID int,
+) (*Message, error) {
+	return c.sendMessage(ctx, chatID, topicID, text, replyToMessageID)
+}
+
+// EditMessageText заменяет текст отправленного ботом сообщения.
+// Повторная отправка того же текста ошибкой не считается.
+func (c *Client) EditMessageText(ctx context.Context, chatID int64, messageID int, text string) error {
+	request := EditMessageTextRequest{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Text:      text,
+	}
+	if err := c.call(ctx, "editMessageText", request, nil); err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message is not modified") {
+			return nil
+		}
+		return fmt.Errorf("ошибка изменения сообщения: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessage удаляет сообщение из чата
+func (c *Client) DeleteMessage(ctx context.Context, chatID int64, messageID int) error {
+	request := DeleteMessageRequest{ChatID: chatID, MessageID: messageID}
+	if err := c.call(ctx, "deleteMessage", request, nil); err != nil {
+		return fmt.Errorf("ошибка удаления сообщения: %w", err)
+	}
+	return nil
+}
+
+// TextLength возвращает длину текста так, как ее считает Telegram (в единицах UTF-16)
+func TextLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length += max(utf16.RuneLen(r), 1)
+	}
+	return length
+}
@@ -0,0 +1,30 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GetUpdatesRequest представляет запрос на получение обновлений через long polling
+type GetUpdatesRequest struct {
+	Offset         int      `json:"offset,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+// GetUpdates получает новые обновления через long polling.
+// Обновления возвращаются в сыром виде, их декодирование остается за вызывающим кодом.
+func (c *Client) GetUpdates(ctx context.Context, request GetUpdatesRequest) ([]json.RawMessage, error) {
+	// Запрос висит на стороне Telegram до Timeout секунд, поэтому даем небольшой запас
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(request.Timeout)*time.Second+15*time.Second)
+	defer cancel()
+
+	var updates []json.RawMessage
+	if err := c.callWithClient(ctx, c.pollClient, "getUpdates", request, &updates); err != nil {
+		return nil, fmt.Errorf("ошибка получения обновлений: %w", err)
+	}
+	return updates, nil
+}
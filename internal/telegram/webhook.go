@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetWebhookRequest представляет запрос на регистрацию webhook
+type SetWebhookRequest struct {
+	URL                string   `json:"url"`
+	SecretToken        string   `json:"secret_token,omitempty"`
+	MaxConnections     int      `json:"max_connections,omitempty"`
+	AllowedUpdates     []string `json:"allowed_updates,omitempty"`
+	DropPendingUpdates bool     `json:"drop_pending_updates,omitempty"`
+}
+
+// DeleteWebhookRequest представляет запрос на удаление webhook
+type DeleteWebhookRequest struct {
+	DropPendingUpdates bool `json:"drop_pending_updates,omitempty"`
+}
+
+// WebhookInfo представляет текущее состояние webhook
+type WebhookInfo struct {
+	URL                          string   `json:"url"`
+	HasCustomCertificate         bool     `json:"has_custom_certificate"`
+	PendingUpdateCount           int      `json:"pending_update_count"`
+	IPAddress                    string   `json:"ip_address,omitempty"`
+	LastErrorDate                int64    `json:"last_error_date,omitempty"`
+	LastErrorMessage             string   `json:"last_error_message,omitempty"`
+	LastSynchronizationErrorDate int64    `json:"last_synchronization_error_date,omitempty"`
+	MaxConnections               int      `json:"max_connections,omitempty"`
+	AllowedUpdates               []string `json:"allowed_updates,omitempty"`
+}
+
+// LastErrorTime возвращает время последней ошибки доставки (нулевое, если ошибок не было)
+func (w *WebhookInfo) LastErrorTime() time.Time {
+	if w.LastErrorDate == 0 {
+		return time.Time{}
+	}
+	return time.Unix(w.LastErrorDate, 0)
+}
+
+// SetWebhook регистрирует webhook в Telegram
+func (c *Client) SetWebhook(ctx context.Context, request SetWebhookRequest) error {
+	if err := c.call(ctx, "setWebhook", request, nil); err != nil {
+		return fmt.Errorf("ошибка регистрации webhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook удаляет зарегистрированный webhook
+func (c *Client) DeleteWebhook(ctx context.Context, dropPendingUpdates bool) error {
+	request := DeleteWebhookRequest{DropPendingUpdates: dropPendingUpdates}
+	if err := c.call(ctx, "deleteWebhook", request, nil); err != nil {
+		return fmt.Errorf("ошибка удаления webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookInfo возвращает текущее состояние webhook
+func (c *Client) GetWebhookInfo(ctx context.Context) (*WebhookInfo, error) {
+	var info WebhookInfo
+	if err := c.call(ctx, "getWebhookInfo", struct{}{}, &info); err != nil {
+		return nil, fmt.Errorf("ошибка получения информации о webhook: %w", err)
+	}
+	return &info, nil
+}
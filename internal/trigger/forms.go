@@ -0,0 +1,94 @@
+package trigger
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Forms возвращает слово и его падежные формы (единственное число, а также именительный падеж
+// множественного числа). Формы строятся по окончанию для склонения имен и существительных:
+// "жорик" → жорика, жорику, жориком, жорике, жорики; "жора" → жоры, жоре, жору, жорой, жорою.
+// Слова не на кириллице и несклоняемые слова возвращаются без изменений.
+func Forms(word string) []string {
+	word = Normalize(word)
+	runes := []rune(word)
+	if len(runes) < 2 || !isCyrillicWord(runes) {
+		return []string{word}
+	}
+
+	last := runes[len(runes)-1]
+	stem := string(runes[:len(runes)-1])
+	prev := runes[len(runes)-2]
+
+	var endings []string
+	switch {
+	case last == 'й':
+		// Андрей → Андрея, Андрею, Андреем, Андрее
+		return withEndings(word, stem, "я", "ю", "ем", "е", "и")
+	case last == 'ь':
+		// Игорь → Игоря, Игорю, Игорем, Игоре; Любовь → Любови, Любовью
+		return withEndings(word, stem, "я", "ю", "ем", "е", "и", "ью")
+	case last == 'а':
+		// Жора → Жоры, Жоре, Жору, Жорой; Миша → Миши, Мише, Мишей
+		endings = []string{"е", "у", "ой", "ою"}
+		if isVelarOrSibilant(prev) {
+			endings = append(endings, "и")
+		} else {
+			endings = append(endings, "ы")
+		}
+		if isSibilant(prev) {
+			endings = append(endings, "ей", "ею")
+		}
+		return withEndings(word, stem, endings...)
+	case last == 'я':
+		// Ваня → Вани, Ване, Ваню, Ваней
+		return withEndings(word, stem, "и", "е", "ю", "ей", "ею")
+	case isConsonant(last):
+		// Жорик → Жорика, Жорику, Жориком, Жорике; Жорж → Жоржем
+		endings = []string{"а", "у", "ом", "е"}
+		if isSibilant(last) {
+			endings = append(endings, "ем")
+		}
+		if isVelarOrSibilant(last) {
+			endings = append(endings, "и")
+		} else {
+			endings = append(endings, "ы")
+		}
+		return withEndings(word, word, endings...)
+	default:
+		// Слова на -о, -е, -и, -у и т.п. (Пьеро, Мари) не склоняются
+		return []string{word}
+	}
+}
+
+func withEndings(word, stem string, endings ...string) []string {
+	forms := make([]string, 0, len(endings)+1)
+	forms = append(forms, word)
+	for _, ending := range endings {
+		forms = append(forms, stem+ending)
+	}
+	return forms
+}
+
+func isCyrillicWord(runes []rune) bool {
+	for _, r := range runes {
+		if !unicode.Is(unicode.Cyrillic, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isConsonant(r rune) bool {
+	return strings.ContainsRune("бвгджзклмнпрстфхцчшщ", r)
+}
+
+// isSibilant - шипящие и "ц", после которых пишется "е" вместо безударного "о"
+func isSibilant(r rune) bool {
+	return strings.ContainsRune("жшчщц", r)
+}
+
+// isVelarOrSibilant - буквы, после которых пишется "и" вместо "ы"
+func isVelarOrSibilant(r rune) bool {
+	return strings.ContainsRune("гкхжшчщ", r)
+}
@@ -0,0 +1,87 @@
+// Package trigger определяет, обращается ли сообщение к боту по имени или слову-триггеру.
+//
+// Текст разбивается на слова с учетом Unicode, поэтому имя совпадает только целым словом
+// ("жорик" не находится внутри "жорать" или "бажора"). Для русских слов автоматически
+// порождаются падежные формы, чтобы "спроси у Жорика" и "Жоре привет" тоже считались обращением.
+package trigger
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Matcher ищет в тексте любое из заданных слов или словосочетаний во всех падежных формах
+type Matcher struct {
+	phrases []phrase
+}
+
+// phrase - слово или словосочетание-триггер; для каждого слова хранится множество его форм
+type phrase struct {
+	source string
+	words  []map[string]bool
+}
+
+// NewMatcher создает Matcher для набора триггеров. Пустые строки игнорируются.
+func NewMatcher(triggers ...string) *Matcher {
+	m := &Matcher{}
+	for _, t := range triggers {
+		tokens := Tokenize(t)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		p := phrase{source: t, words: make([]map[string]bool, len(tokens))}
+		for i, token := range tokens {
+			p.words[i] = make(map[string]bool)
+			for _, form := range Forms(token) {
+				p.words[i][form] = true
+			}
+		}
+		m.phrases = append(m.phrases, p)
+	}
+	return m
+}
+
+// Match возвращает исходный триггер, найденный в тексте
+func (m *Matcher) Match(text string) (string, bool) {
+	if m == nil || len(m.phrases) == 0 {
+		return "", false
+	}
+
+	tokens := Tokenize(text)
+	for _, p := range m.phrases {
+		for start := 0; start+len(p.words) <= len(tokens); start++ {
+			if p.matchesAt(tokens, start) {
+				return p.source, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p phrase) matchesAt(tokens []string, start int) bool {
+	for i, forms := range p.words {
+		if !forms[tokens[start+i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// Tokenize разбивает текст на слова из букв и цифр и нормализует их (нижний регистр, ё → е)
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		tokens = append(tokens, Normalize(field))
+	}
+	return tokens
+}
+
+// Normalize приводит слово к нижнему регистру и заменяет "ё" на "е"
+func Normalize(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
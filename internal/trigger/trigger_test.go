@@ -0,0 +1,87 @@
+package trigger
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestForms(t *testing.T) {
+	tests := []struct {
+		word    string
+		want    []string // Формы, которые должны быть среди результата
+		notWant []string // Формы, которых быть не должно
+	}{
+		{word: "Жорик", want: []string{"жорик", "жорика", "жорику", "жориком", "жорике", "жорики"}, notWant: []string{"жорикы"}},
+		{word: "Жора", want: []string{"жора", "жоры", "жоре", "жору", "жорой", "жорою"}, notWant: []string{"жори", "жорей"}},
+		{word: "Миша", want: []string{"миши", "мише", "мишу", "мишей"}, notWant: []string{"мишы"}},
+		{word: "Ваня", want: []string{"вани", "ване", "ваню", "ваней"}},
+		{word: "Андрей", want: []string{"андрея", "андрею", "андреем", "андрее"}},
+		{word: "Игорь", want: []string{"игоря", "игорю", "игорем", "игоре"}},
+		{word: "Жорж", want: []string{"жоржа", "жоржем", "жоржи"}},
+		{word: "Пьеро", want: []string{"пьеро"}, notWant: []string{"пьера"}},
+		{word: "Ёжик", want: []string{"ежик", "ежика"}},
+		{word: "bot", want: []string{"bot"}, notWant: []string{"bota"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			forms := Forms(tt.word)
+			for _, form := range tt.want {
+				if !slices.Contains(forms, form) {
+					t.Errorf("Forms(%q) = %v, нет формы %q", tt.word, forms, form)
+				}
+			}
+			for _, form := range tt.notWant {
+				if slices.Contains(forms, form) {
+					t.Errorf("Forms(%q) = %v, лишняя форма %q", tt.word, forms, form)
+				}
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	matcher := NewMatcher("Жорик", "", "старый хрыч", "bot")
+
+	tests := []struct {
+		text      string
+		wantMatch string
+		wantOK    bool
+	}{
+		{text: "Жорик, привет", wantMatch: "Жорик", wantOK: true},
+		{text: "спроси у жорика", wantMatch: "Жорик", wantOK: true},
+		{text: "ЖОРИКУ передай", wantMatch: "Жорик", wantOK: true},
+		{text: "ну что, старый хрыч?", wantMatch: "старый хрыч", wantOK: true},
+		{text: "старый добрый хрыч", wantOK: false},
+		{text: "hey bot!", wantMatch: "bot", wantOK: true},
+		{text: "robot", wantOK: false},
+		{text: "жорать охота", wantOK: false},
+		{text: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			match, ok := matcher.Match(tt.text)
+			if ok != tt.wantOK || match != tt.wantMatch {
+				t.Errorf("Match(%q) = %q, %t, ожидалось %q, %t", tt.text, match, ok, tt.wantMatch, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatcherEmpty(t *testing.T) {
+	var nilMatcher *Matcher
+	for _, matcher := range []*Matcher{nilMatcher, NewMatcher(), NewMatcher("", "  ")} {
+		if match, ok := matcher.Match("Жорик"); ok {
+			t.Errorf("пустой Matcher нашел %q", match)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Ёлки-палки, Жорик!  42 раза")
+	want := []string{"елки", "палки", "жорик", "42", "раза"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Tokenize = %v, ожидалось %v", got, want)
+	}
+}